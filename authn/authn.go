@@ -24,17 +24,26 @@ type IToken interface {
 	EncodeToJSON() ([]byte, error)
 }
 
+// Verifier defines methods used to verify tokens, such as the tokens issued
+// by an external identity provider.
+type Verifier interface {
+	// ParseClaims parse the token and return the claims.
+	ParseClaims(ctx context.Context, accessToken string) (*jwt.RegisteredClaims, error)
+}
+
 // Authenticator defines methods used for token processing.
 type Authenticator interface {
-	// Sign is used to generate a token.
-	Sign(ctx context.Context, userID string) (IToken, error)
+	Verifier
+
+	// Sign is used to generate a token. The ext maps are added to the token claims.
+	Sign(ctx context.Context, userID string, ext ...map[string]any) (IToken, error)
+
+	// Refresh exchanges a refresh token for a new token.
+	Refresh(ctx context.Context, refreshToken string) (IToken, error)
 
 	// Destroy is used to destroy a token.
 	Destroy(ctx context.Context, accessToken string) error
 
-	// ParseClaims parse the token and return the claims.
-	ParseClaims(ctx context.Context, accessToken string) (*jwt.RegisteredClaims, error)
-
 	// Release used to release the requested resources.
 	Release() error
 }
@@ -0,0 +1,137 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package jwt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/snail-plus/gopkg/authn"
+	"github.com/snail-plus/gopkg/i18n"
+)
+
+// reservedClaims are the claims set by JWTAuth, which private claims cannot override.
+var reservedClaims = []string{"iss", "sub", "aud", "exp", "nbf", "iat", "jti", "fam", "auth_time"}
+
+// claims are the claims of the tokens issued by JWTAuth.
+type claims struct {
+	jwt.RegisteredClaims
+	// Family identifies the tokens issued from the same sign in, through
+	// successive refreshes. Reusing a refresh token revokes the whole family.
+	Family string `json:"fam,omitempty"`
+	// AuthTime is when the user signed in, MaxRefresh counts from it.
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+}
+
+// merge returns the payload of a token holding the claims and the private claims.
+func (c *claims) merge(extra map[string]any) (jwt.MapClaims, error) {
+	payload := jwt.MapClaims{}
+	for k, v := range extra {
+		payload[k] = v
+	}
+	for _, k := range reservedClaims {
+		delete(payload, k)
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+// decodePayload decodes the payload of a parsed token into v.
+func decodePayload(token *jwt.Token, v any) error {
+	parts := strings.Split(token.Raw, ".")
+	if len(parts) != 3 {
+		return jwt.ErrTokenMalformed
+	}
+
+	data, err := jwt.NewParser().DecodeSegment(parts[1])
+	if err != nil {
+		return err
+	}
+
+	// Numbers are kept as json.Number when decoded into maps, so that they
+	// are signed again unchanged.
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// privateClaims returns the claims of a parsed token that are not reserved.
+func privateClaims(token *jwt.Token) (map[string]any, error) {
+	extra := map[string]any{}
+	if err := decodePayload(token, &extra); err != nil {
+		return nil, err
+	}
+	for _, k := range reservedClaims {
+		delete(extra, k)
+	}
+
+	return extra, nil
+}
+
+// SignClaims is used to generate a token whose payload holds the custom
+// claims, such as a struct with the tenant, roles and scopes of the user.
+// The custom claims must encode to a JSON object. The registered claims,
+// the subject included, are set by JWTAuth and override those of the custom
+// claims.
+func (a *JWTAuth) SignClaims(ctx context.Context, userID string, custom any) (authn.IToken, error) {
+	data, err := json.Marshal(custom)
+	if err != nil {
+		return nil, errors.New(i18n.FromContext(ctx).LocalizeT(MessageSignTokenFailed))
+	}
+
+	extra := map[string]any{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&extra); err != nil {
+		return nil, errors.New(i18n.FromContext(ctx).LocalizeT(MessageSignTokenFailed))
+	}
+
+	return a.Sign(ctx, userID, extra)
+}
+
+// ParseCustomClaims parse the access token like ParseClaims, and decodes its
+// payload into the claims created by the claims factory, a *jwt.MapClaims by
+// default. See WithClaimsFactory.
+func (a *JWTAuth) ParseCustomClaims(ctx context.Context, accessToken string) (jwt.Claims, error) {
+	token, _, err := a.parseAccessToken(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := a.opts.claimsFactory()
+	if err := decodePayload(token, claims); err != nil {
+		return nil, errors.New(i18n.FromContext(ctx).LocalizeT(MessageTokenParseFail))
+	}
+
+	return claims, nil
+}
+
+// ParseClaimsAs parse the access token like ParseClaims, and decodes its
+// payload into a C. C is usually a struct embedding jwt.RegisteredClaims
+// along with the custom claims signed with SignClaims.
+func ParseClaimsAs[C any](ctx context.Context, a *JWTAuth, accessToken string) (*C, error) {
+	token, _, err := a.parseAccessToken(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := new(C)
+	if err := decodePayload(token, claims); err != nil {
+		return nil, errors.New(i18n.FromContext(ctx).LocalizeT(MessageTokenParseFail))
+	}
+
+	return claims, nil
+}
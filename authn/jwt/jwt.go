@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	goi18n "github.com/nicksnyder/go-i18n/v2/i18n"
 
 	"github.com/snail-plus/gopkg/authn"
@@ -28,6 +29,10 @@ var (
 	ErrTokenParseFail         = errors.New("fail to parse token")
 	ErrUnSupportSigningMethod = errors.New("wrong signing method")
 	ErrSignTokenFailed        = errors.New("failed to sign token")
+	ErrTokenTypeMismatch      = errors.New("wrong token type")
+	ErrTokenReused            = errors.New("refresh token has already been used")
+	ErrTokenRevoked           = errors.New("token has been revoked")
+	ErrTokenMissing           = errors.New("token is missing")
 )
 
 // Define i18n messages.
@@ -37,6 +42,19 @@ var (
 	MessageTokenParseFail         = &goi18n.Message{ID: "jwt.token.parse.failed", Other: ErrTokenParseFail.Error()}
 	MessageUnSupportSigningMethod = &goi18n.Message{ID: "jwt.wrong.signing.method", Other: ErrUnSupportSigningMethod.Error()}
 	MessageSignTokenFailed        = &goi18n.Message{ID: "jwt.token.sign.failed", Other: ErrSignTokenFailed.Error()}
+	MessageTokenTypeMismatch      = &goi18n.Message{ID: "jwt.token.type.mismatch", Other: ErrTokenTypeMismatch.Error()}
+	MessageTokenReused            = &goi18n.Message{ID: "jwt.token.reused", Other: ErrTokenReused.Error()}
+	MessageTokenRevoked           = &goi18n.Message{ID: "jwt.token.revoked", Other: ErrTokenRevoked.Error()}
+	MessageTokenMissing           = &goi18n.Message{ID: "jwt.token.missing", Other: ErrTokenMissing.Error()}
+)
+
+const (
+	// tokenTypeHeader is the token header holding the TokenType.
+	tokenTypeHeader = "tokenType"
+	// usedRefreshKeyPrefix prefixes the storage keys of the refresh tokens already exchanged.
+	usedRefreshKeyPrefix = "refresh-used:"
+	// revokedFamilyKeyPrefix prefixes the storage keys of the revoked token families.
+	revokedFamilyKeyPrefix = "family-revoked:"
 )
 
 var defaultOptions = options{
@@ -44,20 +62,20 @@ var defaultOptions = options{
 	expired:       2 * time.Hour,
 	signingMethod: jwt.SigningMethodHS256,
 	signingKey:    []byte(defaultKey),
-	keyfunc: func(t *jwt.Token) (any, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrTokenInvalid
-		}
-		return []byte(defaultKey), nil
-	},
+	claimsFactory: func() jwt.Claims { return &jwt.MapClaims{} },
 }
 
 type options struct {
 	signingMethod jwt.SigningMethod
 	signingKey    any
 	keyfunc       jwt.Keyfunc
+	keySet        *KeySet
 	issuer        string
+	audience      string
+	leeway        time.Duration
+	claimsFactory func() jwt.Claims
 	expired       time.Duration
+	maxRefresh    time.Duration
 	tokenType     string
 	tokenHeader   map[string]any
 }
@@ -72,13 +90,37 @@ func WithSigningMethod(method jwt.SigningMethod) Option {
 	}
 }
 
-// WithIssuer set token issuer which is identifies the principal that issued the JWT.
+// WithIssuer set token issuer which is identifies the principal that issued
+// the JWT. Tokens from other issuers are rejected.
 func WithIssuer(issuer string) Option {
 	return func(o *options) {
 		o.issuer = issuer
 	}
 }
 
+// WithAudience set token audience which identifies the recipients the JWT is
+// intended for. Tokens not intended for the audience are rejected.
+func WithAudience(audience string) Option {
+	return func(o *options) {
+		o.audience = audience
+	}
+}
+
+// WithLeeway set the clock skew tolerated when validating the token times.
+func WithLeeway(leeway time.Duration) Option {
+	return func(o *options) {
+		o.leeway = leeway
+	}
+}
+
+// WithClaimsFactory set the function creating the claims returned by
+// ParseCustomClaims, which decodes the token payload into them.
+func WithClaimsFactory(factory func() jwt.Claims) Option {
+	return func(o *options) {
+		o.claimsFactory = factory
+	}
+}
+
 // WithSigningKey set the signature key.
 func WithSigningKey(key any) Option {
 	return func(o *options) {
@@ -86,7 +128,17 @@ func WithSigningKey(key any) Option {
 	}
 }
 
-// WithKeyfunc set the callback function for verifying the key.
+// WithKeySet set the key set signing and verifying the tokens, which takes
+// precedence over the signing method and key. The tokens are signed with the
+// active key of the set.
+func WithKeySet(keySet *KeySet) Option {
+	return func(o *options) {
+		o.keySet = keySet
+	}
+}
+
+// WithKeyfunc set the callback function for verifying the key. By default,
+// tokens are verified with the key set, or the key matching the signing key.
 func WithKeyfunc(keyFunc jwt.Keyfunc) Option {
 	return func(o *options) {
 		o.keyfunc = keyFunc
@@ -100,6 +152,17 @@ func WithExpired(expired time.Duration) Option {
 	}
 }
 
+// WithMaxRefresh set the absolute lifetime of a session: how long after
+// signing in a user can refresh its token. The refresh tokens expire at the
+// latest MaxRefresh after signing in, so it must exceed the access token
+// expiration for refreshing to extend a session. Zero means refresh tokens can
+// be exchanged indefinitely.
+func WithMaxRefresh(maxRefresh time.Duration) Option {
+	return func(o *options) {
+		o.maxRefresh = maxRefresh
+	}
+}
+
 // WithTokenHeader set the customer tokenHeader for client side.
 func WithTokenHeader(header map[string]any) Option {
 	return func(o *options) {
@@ -114,9 +177,33 @@ func New(store Storer, opts ...Option) *JWTAuth {
 		opt(&o)
 	}
 
+	if o.keyfunc == nil {
+		if o.keySet != nil {
+			o.keyfunc = o.keySet.Keyfunc
+		} else {
+			o.keyfunc = keyfuncOf(o.signingMethod, o.signingKey)
+		}
+	}
+
 	return &JWTAuth{opts: &o, store: store}
 }
 
+// keyfuncOf returns the function verifying the tokens signed with the signing key.
+func keyfuncOf(method jwt.SigningMethod, signingKey any) jwt.Keyfunc {
+	key, err := verificationKey(signingKey)
+	return func(t *jwt.Token) (any, error) {
+		if err != nil {
+			return nil, err
+		}
+		if t.Method.Alg() != method.Alg() {
+			return nil, ErrUnSupportSigningMethod
+		}
+		return key, nil
+	}
+}
+
+var _ authn.Authenticator = (*JWTAuth)(nil)
+
 // JWTAuth implement the authn.Authenticator interface.
 type JWTAuth struct {
 	opts  *options
@@ -130,30 +217,159 @@ const (
 	RefreshToken TokenType = "refreshToken"
 )
 
-// Sign is used to generate a token.
+// Sign is used to generate a token. The ext maps are signed into the token
+// payload as private claims, they cannot override the registered claims.
 func (a *JWTAuth) Sign(ctx context.Context, userID string, ext ...map[string]any) (authn.IToken, error) {
+	extra := map[string]any{}
+	for _, m := range ext {
+		for k, v := range m {
+			extra[k] = v
+		}
+	}
+
+	return a.issue(ctx, userID, uuid.New().String(), time.Now(), extra)
+}
+
+// Refresh exchanges a refresh token for a new access and refresh token pair.
+//
+// Refresh tokens are single use: when a store is set, an exchanged refresh
+// token is remembered until it expires, and presenting it again revokes every
+// token of its family, which is the chain of tokens issued from the same
+// sign in. A stolen refresh token is then useless once either the thief or
+// the user has refreshed with it. The one-time use is only guaranteed when
+// the store implements AtomicStorer: with any other store, a refresh token
+// presented concurrently may be exchanged more than once. Without a store,
+// refresh tokens can be exchanged until they expire.
+//
+// Refresh tokens cannot be exchanged past MaxRefresh after signing in.
+func (a *JWTAuth) Refresh(ctx context.Context, refreshToken string) (authn.IToken, error) {
+	if refreshToken == "" {
+		return nil, errors.New(i18n.FromContext(ctx).LocalizeT(MessageTokenInvalid))
+	}
+
+	token, claims, err := a.parseToken(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if tokenType(token) != RefreshToken || claims.Family == "" || claims.AuthTime == nil {
+		return nil, errors.New(i18n.FromContext(ctx).LocalizeT(MessageTokenTypeMismatch))
+	}
+
+	if a.opts.maxRefresh > 0 && time.Since(claims.AuthTime.Time) > a.opts.maxRefresh {
+		return nil, errors.New(i18n.FromContext(ctx).LocalizeT(MessageTokenExpired))
+	}
+
+	store := func(store Storer) error {
+		if err := a.checkRevoked(ctx, store, refreshToken, claims); err != nil {
+			return err
+		}
+
+		// The token is marked as used until it expires, after which it is
+		// rejected anyway. A token accepted within the leeway past its
+		// expiration is still marked, for a positive TTL.
+		ttl := max(time.Until(claims.ExpiresAt.Time)+a.opts.leeway, time.Second)
+		first, err := setIfAbsent(ctx, store, usedRefreshKeyPrefix+claims.ID, ttl)
+		if err != nil {
+			return err
+		}
+		if !first {
+			// Every token of the family expires within the refresh token lifetime.
+			if err := store.Set(ctx, revokedFamilyKeyPrefix+claims.Family, a.opts.expired*3); err != nil {
+				return err
+			}
+			return errors.New(i18n.FromContext(ctx).LocalizeT(MessageTokenReused))
+		}
+
+		return nil
+	}
+	if err := a.callStore(store); err != nil {
+		return nil, err
+	}
+
+	// Carry the private claims over to the new tokens.
+	extra, err := privateClaims(token)
+	if err != nil {
+		return nil, errors.New(i18n.FromContext(ctx).LocalizeT(MessageTokenParseFail))
+	}
 
+	return a.issue(ctx, claims.Subject, claims.Family, claims.AuthTime.Time, extra)
+}
+
+// setIfAbsent stores the key unless it is already stored, and reports whether
+// it was stored. The check and the write are only atomic when the store
+// implements AtomicStorer.
+func setIfAbsent(ctx context.Context, store Storer, key string, expiration time.Duration) (bool, error) {
+	if atomic, ok := store.(AtomicStorer); ok {
+		return atomic.SetIfAbsent(ctx, key, expiration)
+	}
+
+	exists, err := store.Check(ctx, key)
+	if err != nil || exists {
+		return false, err
+	}
+
+	return true, store.Set(ctx, key, expiration)
+}
+
+// issue generates the access and refresh tokens of a family.
+func (a *JWTAuth) issue(
+	ctx context.Context,
+	userID string,
+	family string,
+	authTime time.Time,
+	extra map[string]any,
+) (authn.IToken, error) {
+	now := time.Now()
 	genTokenFn := func(tokenType TokenType) (string, error) {
-		now := time.Now()
 		var expiresAt time.Time
 		if tokenType == AccessToken {
 			expiresAt = now.Add(a.opts.expired)
 		} else {
 			expiresAt = now.Add(a.opts.expired * 3)
+			if maxRefreshAt := authTime.Add(a.opts.maxRefresh); a.opts.maxRefresh > 0 && maxRefreshAt.Before(expiresAt) {
+				expiresAt = maxRefreshAt
+			}
+		}
+
+		c := &claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				// Issuer = iss,令牌颁发者。它表示该令牌是由谁创建的
+				Issuer: a.opts.issuer,
+				// IssuedAt = iat,令牌颁发时的时间戳。它表示令牌是何时被创建的
+				IssuedAt: jwt.NewNumericDate(now),
+				// ExpiresAt = exp,令牌的过期时间戳。它表示令牌将在何时过期
+				ExpiresAt: jwt.NewNumericDate(expiresAt),
+				// NotBefore = nbf,令牌的生效时的时间戳。它表示令牌从什么时候开始生效
+				NotBefore: jwt.NewNumericDate(now),
+				// Subject = sub,令牌的主体。它表示该令牌是关于谁的
+				Subject: userID,
+				// ID = jti,令牌的唯一标识。刷新令牌只能使用一次
+				ID: uuid.New().String(),
+			},
+			Family:   family,
+			AuthTime: jwt.NewNumericDate(authTime),
+		}
+		if a.opts.audience != "" {
+			c.Audience = jwt.ClaimStrings{a.opts.audience}
+		}
+
+		payload, err := c.merge(extra)
+		if err != nil {
+			return "", errors.New(i18n.FromContext(ctx).LocalizeT(MessageSignTokenFailed))
+		}
+
+		method, signingKey := a.opts.signingMethod, a.opts.signingKey
+		var kid string
+		if a.opts.keySet != nil {
+			key, err := a.opts.keySet.Active()
+			if err != nil {
+				return "", errors.New(i18n.FromContext(ctx).LocalizeT(MessageSignTokenFailed))
+			}
+			method, signingKey, kid = key.Method, key.Private, key.ID
 		}
 
-		token := jwt.NewWithClaims(a.opts.signingMethod, &jwt.RegisteredClaims{
-			// Issuer = iss,令牌颁发者。它表示该令牌是由谁创建的
-			Issuer: a.opts.issuer,
-			// IssuedAt = iat,令牌颁发时的时间戳。它表示令牌是何时被创建的
-			IssuedAt: jwt.NewNumericDate(now),
-			// ExpiresAt = exp,令牌的过期时间戳。它表示令牌将在何时过期
-			ExpiresAt: jwt.NewNumericDate(expiresAt),
-			// NotBefore = nbf,令牌的生效时的时间戳。它表示令牌从什么时候开始生效
-			NotBefore: jwt.NewNumericDate(now),
-			// Subject = sub,令牌的主体。它表示该令牌是关于谁的
-			Subject: userID,
-		})
+		token := jwt.NewWithClaims(method, payload)
 
 		if a.opts.tokenHeader != nil {
 			for k, v := range a.opts.tokenHeader {
@@ -161,14 +377,12 @@ func (a *JWTAuth) Sign(ctx context.Context, userID string, ext ...map[string]any
 			}
 		}
 
-		token.Header["tokenType"] = tokenType
-		if len(ext) > 0 {
-			for k, v := range ext[0] {
-				token.Header[k] = v
-			}
+		token.Header[tokenTypeHeader] = tokenType
+		if kid != "" {
+			token.Header["kid"] = kid
 		}
 
-		jwtToken, err := token.SignedString(a.opts.signingKey)
+		jwtToken, err := token.SignedString(signingKey)
 		if err != nil {
 			return "", errors.New(i18n.FromContext(ctx).LocalizeT(MessageSignTokenFailed))
 		}
@@ -186,42 +400,85 @@ func (a *JWTAuth) Sign(ctx context.Context, userID string, ext ...map[string]any
 	}
 
 	return &tokenInfo{
-		ExpiresAt:    time.Now().Add(a.opts.expired).Unix(),
+		ExpiresAt:    now.Add(a.opts.expired).Unix(),
 		Type:         a.opts.tokenType,
 		Token:        accessToken,
 		RefreshToken: refreshToken,
 	}, nil
 }
 
-// parseToken is used to parse the input refreshToken.
-func (a *JWTAuth) parseToken(ctx context.Context, refreshToken string) (*jwt.RegisteredClaims, error) {
-	token, err := jwt.ParseWithClaims(refreshToken, &jwt.RegisteredClaims{}, a.opts.keyfunc)
+// tokenType returns the type of the token, as set in its header.
+func tokenType(token *jwt.Token) TokenType {
+	t, _ := token.Header[tokenTypeHeader].(string)
+	return TokenType(t)
+}
+
+// parseToken is used to parse the input token.
+func (a *JWTAuth) parseToken(ctx context.Context, tokenString string) (*jwt.Token, *claims, error) {
+	parserOptions := []jwt.ParserOption{jwt.WithLeeway(a.opts.leeway)}
+	if a.opts.issuer != "" {
+		parserOptions = append(parserOptions, jwt.WithIssuer(a.opts.issuer))
+	}
+	if a.opts.audience != "" {
+		parserOptions = append(parserOptions, jwt.WithAudience(a.opts.audience))
+	}
+
+	token, err := jwt.ParseWithClaims(tokenString, &claims{}, a.opts.keyfunc, parserOptions...)
 	if err != nil {
+		return nil, nil, parseError(ctx, err)
+	}
 
-		if errors.Is(err, jwt.ErrTokenMalformed) {
-			return nil, errors.New(i18n.FromContext(ctx).LocalizeT(MessageTokenInvalid))
-		}
+	if !token.Valid {
+		return nil, nil, errors.New(i18n.FromContext(ctx).LocalizeT(MessageTokenInvalid))
+	}
 
-		if errors.Is(err, jwt.ErrTokenNotValidYet) {
-			return nil, errors.New(i18n.FromContext(ctx).LocalizeT(MessageTokenExpired))
-		}
+	// The key set checks the method of every key.
+	if a.opts.keySet == nil && token.Method.Alg() != a.opts.signingMethod.Alg() {
+		return nil, nil, errors.New(i18n.FromContext(ctx).LocalizeT(MessageUnSupportSigningMethod))
+	}
 
-		if errors.Is(err, jwt.ErrTokenExpired) {
-			return nil, errors.New(i18n.FromContext(ctx).LocalizeT(MessageTokenExpired))
-		}
+	return token, token.Claims.(*claims), nil
+}
 
-		return nil, errors.New(i18n.FromContext(ctx).LocalizeT(MessageTokenParseFail))
+// parseError converts an error of the jwt parser into a localized error.
+func parseError(ctx context.Context, err error) error {
+	if errors.Is(err, jwt.ErrTokenMalformed) {
+		return errors.New(i18n.FromContext(ctx).LocalizeT(MessageTokenInvalid))
 	}
 
-	if !token.Valid {
-		return nil, errors.New(i18n.FromContext(ctx).LocalizeT(MessageTokenInvalid))
+	if errors.Is(err, jwt.ErrTokenNotValidYet) {
+		return errors.New(i18n.FromContext(ctx).LocalizeT(MessageTokenExpired))
+	}
+
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return errors.New(i18n.FromContext(ctx).LocalizeT(MessageTokenExpired))
+	}
+
+	return errors.New(i18n.FromContext(ctx).LocalizeT(MessageTokenParseFail))
+}
+
+// checkRevoked returns an error if the token was destroyed, or if its family was revoked.
+func (a *JWTAuth) checkRevoked(ctx context.Context, store Storer, tokenString string, claims *claims) error {
+	exists, err := store.Check(ctx, tokenString)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return errors.New(i18n.FromContext(ctx).LocalizeT(MessageTokenInvalid))
 	}
 
-	if token.Method != a.opts.signingMethod {
-		return nil, errors.New(i18n.FromContext(ctx).LocalizeT(MessageUnSupportSigningMethod))
+	if claims.Family == "" {
+		return nil
+	}
+	revoked, err := store.Check(ctx, revokedFamilyKeyPrefix+claims.Family)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return errors.New(i18n.FromContext(ctx).LocalizeT(MessageTokenRevoked))
 	}
 
-	return token.Claims.(*jwt.RegisteredClaims), nil
+	return nil
 }
 
 func (a *JWTAuth) callStore(fn func(Storer) error) error {
@@ -233,7 +490,7 @@ func (a *JWTAuth) callStore(fn func(Storer) error) error {
 
 // Destroy is used to destroy a token.
 func (a *JWTAuth) Destroy(ctx context.Context, refreshToken string) error {
-	claims, err := a.parseToken(ctx, refreshToken)
+	_, claims, err := a.parseToken(ctx, refreshToken)
 	if err != nil {
 		return err
 	}
@@ -246,35 +503,41 @@ func (a *JWTAuth) Destroy(ctx context.Context, refreshToken string) error {
 	return a.callStore(store)
 }
 
-// ParseClaims parse the token and return the claims.
-func (a *JWTAuth) ParseClaims(ctx context.Context, refreshToken string) (*jwt.RegisteredClaims, error) {
-	if refreshToken == "" {
-		return nil, errors.New(i18n.FromContext(ctx).LocalizeT(MessageTokenInvalid))
-	}
-
-	claims, err := a.parseToken(ctx, refreshToken)
+// ParseClaims parse the access token and return the claims. Refresh tokens
+// are rejected, they can only be exchanged with Refresh.
+func (a *JWTAuth) ParseClaims(ctx context.Context, accessToken string) (*jwt.RegisteredClaims, error) {
+	_, claims, err := a.parseAccessToken(ctx, accessToken)
 	if err != nil {
 		return nil, err
 	}
 
-	store := func(store Storer) error {
-		exists, err := store.Check(ctx, refreshToken)
-		if err != nil {
-			return err
-		}
+	return &claims.RegisteredClaims, nil
+}
 
-		if exists {
-			return errors.New(i18n.FromContext(ctx).LocalizeT(MessageTokenInvalid))
-		}
+// parseAccessToken parses and validates the access token.
+func (a *JWTAuth) parseAccessToken(ctx context.Context, accessToken string) (*jwt.Token, *claims, error) {
+	if accessToken == "" {
+		return nil, nil, errors.New(i18n.FromContext(ctx).LocalizeT(MessageTokenInvalid))
+	}
 
-		return nil
+	token, claims, err := a.parseToken(ctx, accessToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if tokenType(token) == RefreshToken {
+		return nil, nil, errors.New(i18n.FromContext(ctx).LocalizeT(MessageTokenTypeMismatch))
+	}
+
+	store := func(store Storer) error {
+		return a.checkRevoked(ctx, store, accessToken, claims)
 	}
 
 	if err := a.callStore(store); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return claims, nil
+	return token, claims, nil
 }
 
 // Release used to release the requested resources.
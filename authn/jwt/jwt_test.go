@@ -0,0 +1,219 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package jwt
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var _ AtomicStorer = (*memoryStore)(nil)
+
+// memoryStore is an in-memory AtomicStorer.
+type memoryStore struct {
+	mu   sync.Mutex
+	keys map[string]time.Time
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{keys: make(map[string]time.Time)}
+}
+
+func (s *memoryStore) Set(_ context.Context, key string, expiration time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[key] = time.Now().Add(expiration)
+	return nil
+}
+
+func (s *memoryStore) SetIfAbsent(_ context.Context, key string, expiration time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Like redis, reject the keys that would never or already expire.
+	if expiration <= 0 {
+		return false, errors.New("invalid expire time")
+	}
+	if expiresAt, ok := s.keys[key]; ok && time.Now().Before(expiresAt) {
+		return false, nil
+	}
+	s.keys[key] = time.Now().Add(expiration)
+	return true, nil
+}
+
+func (s *memoryStore) Delete(_ context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.keys[key]
+	delete(s.keys, key)
+	return ok, nil
+}
+
+func (s *memoryStore) Check(_ context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiresAt, ok := s.keys[key]
+	return ok && time.Now().Before(expiresAt), nil
+}
+
+func (s *memoryStore) Close() error {
+	return nil
+}
+
+// plainStore hides the SetIfAbsent method of a memoryStore.
+type plainStore struct {
+	Storer
+}
+
+func TestRefresh(t *testing.T) {
+	ctx := context.Background()
+	auth := New(newMemoryStore())
+
+	token, err := auth.Sign(ctx, "user", map[string]any{"tenant": "acme"})
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if _, err := auth.ParseClaims(ctx, token.GetRefreshToken()); err == nil {
+		t.Error("ParseClaims() accepted a refresh token")
+	}
+	if _, err := auth.Refresh(ctx, token.GetToken()); err == nil {
+		t.Error("Refresh() accepted an access token")
+	}
+
+	refreshed, err := auth.Refresh(ctx, token.GetRefreshToken())
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	claims, err := auth.ParseClaims(ctx, refreshed.GetToken())
+	if err != nil || claims.Subject != "user" {
+		t.Fatalf("ParseClaims() = %v, %v, want the claims of user", claims, err)
+	}
+
+	// Reusing the first refresh token revokes the whole family.
+	if _, err := auth.Refresh(ctx, token.GetRefreshToken()); err == nil {
+		t.Fatal("Refresh() accepted a reused refresh token")
+	}
+	if _, err := auth.Refresh(ctx, refreshed.GetRefreshToken()); err == nil {
+		t.Error("Refresh() accepted a refresh token of a revoked family")
+	}
+	if _, err := auth.ParseClaims(ctx, refreshed.GetToken()); err == nil {
+		t.Error("ParseClaims() accepted an access token of a revoked family")
+	}
+
+	// Other families are not affected.
+	other, _ := auth.Sign(ctx, "user")
+	if _, err := auth.Refresh(ctx, other.GetRefreshToken()); err != nil {
+		t.Errorf("Refresh() error = %v", err)
+	}
+}
+
+func TestRefreshWithoutAtomicStore(t *testing.T) {
+	ctx := context.Background()
+	auth := New(plainStore{newMemoryStore()})
+
+	token, err := auth.Sign(ctx, "user")
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if _, err := auth.Refresh(ctx, token.GetRefreshToken()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if _, err := auth.Refresh(ctx, token.GetRefreshToken()); err == nil {
+		t.Error("Refresh() accepted a reused refresh token")
+	}
+}
+
+func TestRefreshMaxRefresh(t *testing.T) {
+	ctx := context.Background()
+	auth := New(newMemoryStore(), WithMaxRefresh(time.Second))
+
+	token, err := auth.Sign(ctx, "user")
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	if _, err := auth.Refresh(ctx, token.GetRefreshToken()); err == nil {
+		t.Error("Refresh() accepted a token past MaxRefresh")
+	}
+}
+
+func TestRefreshWithinLeeway(t *testing.T) {
+	ctx := context.Background()
+	// The tokens are signed already expired, as by a server whose clock is ahead.
+	auth := New(newMemoryStore(), WithExpired(-time.Second), WithLeeway(time.Minute))
+
+	token, err := auth.Sign(ctx, "user")
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if _, err := auth.Refresh(ctx, token.GetRefreshToken()); err != nil {
+		t.Fatalf("Refresh() error = %v, want the token accepted within the leeway", err)
+	}
+	if _, err := auth.Refresh(ctx, token.GetRefreshToken()); err == nil {
+		t.Error("Refresh() accepted a reused token")
+	}
+}
+
+type userClaims struct {
+	jwt.RegisteredClaims
+	TenantID string   `json:"tenant_id"`
+	Roles    []string `json:"roles"`
+	Level    int      `json:"level"`
+}
+
+func TestCustomClaims(t *testing.T) {
+	ctx := context.Background()
+	auth := New(newMemoryStore(), WithIssuer("onex"), WithAudience("api"))
+
+	custom := &userClaims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "spoofed"},
+		TenantID:         "acme",
+		Roles:            []string{"admin"},
+		Level:            1000000,
+	}
+	token, err := auth.SignClaims(ctx, "user", custom)
+	if err != nil {
+		t.Fatalf("SignClaims() error = %v", err)
+	}
+
+	// Private claims survive a refresh.
+	token, err = auth.Refresh(ctx, token.GetRefreshToken())
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	claims, err := ParseClaimsAs[userClaims](ctx, auth, token.GetToken())
+	if err != nil {
+		t.Fatalf("ParseClaimsAs() error = %v", err)
+	}
+	if claims.Subject != "user" || claims.TenantID != "acme" || len(claims.Roles) != 1 || claims.Level != 1000000 {
+		t.Errorf("ParseClaimsAs() = %+v, want the signed claims", claims)
+	}
+
+	mapClaims, err := auth.ParseCustomClaims(ctx, token.GetToken())
+	if err != nil {
+		t.Fatalf("ParseCustomClaims() error = %v", err)
+	}
+	if tenant := (*mapClaims.(*jwt.MapClaims))["tenant_id"]; tenant != "acme" {
+		t.Errorf("ParseCustomClaims() tenant_id = %v, want acme", tenant)
+	}
+
+	// Tokens of other issuers or audiences are rejected.
+	for _, other := range []*JWTAuth{
+		New(nil, WithIssuer("other"), WithAudience("api")),
+		New(nil, WithIssuer("onex"), WithAudience("admin")),
+	} {
+		if _, err := other.ParseClaims(ctx, token.GetToken()); err == nil {
+			t.Error("ParseClaims() accepted a token of another issuer or audience")
+		}
+	}
+}
@@ -0,0 +1,280 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// ErrKeyNotFound is returned when a token names a key that is not in the key set.
+	ErrKeyNotFound = errors.New("signing key not found")
+	// ErrNoSigningKey is returned when signing with a key set without an active private key.
+	ErrNoSigningKey = errors.New("no active signing key")
+	// ErrUnsupportedKey is returned for PEM blocks and keys of an unsupported type.
+	ErrUnsupportedKey = errors.New("unsupported key type")
+)
+
+// LoadPrivateKey reads a PEM encoded RSA, ECDSA or Ed25519 private key from a file.
+func LoadPrivateKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ParsePrivateKeyPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load private key %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// LoadPublicKey reads a PEM encoded RSA, ECDSA or Ed25519 public key, or
+// certificate, from a file.
+func LoadPublicKey(path string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ParsePublicKeyPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load public key %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// ParsePrivateKeyPEM parses a PKCS #8, PKCS #1 (RSA) or SEC 1 (EC) private key.
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%w: PEM block %q", ErrUnsupportedKey, block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+		return k.(crypto.Signer), nil
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, key)
+	}
+}
+
+// ParsePublicKeyPEM parses a PKIX or PKCS #1 (RSA) public key, or the public key of a certificate.
+func ParsePublicKeyPEM(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		cert, err = x509.ParseCertificate(block.Bytes)
+		if err == nil {
+			key = cert.PublicKey
+		}
+	default:
+		return nil, fmt.Errorf("%w: PEM block %q", ErrUnsupportedKey, block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, key)
+	}
+}
+
+// verificationKey returns the key verifying the signatures made with the signing key.
+func verificationKey(signingKey any) (any, error) {
+	switch k := signingKey.(type) {
+	case []byte:
+		return k, nil
+	case string:
+		return []byte(k), nil
+	case crypto.Signer:
+		return k.Public(), nil
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, signingKey)
+	}
+}
+
+// Key is a key of a KeySet.
+type Key struct {
+	// ID is the key identifier, set in the kid header of the tokens it signs.
+	ID string
+	// Method is the signing method of the key.
+	Method jwt.SigningMethod
+	// Private is the signing key: a []byte secret for HMAC, or a private key.
+	// It is nil for the keys that only verify tokens, such as retired keys.
+	Private any
+	// Public is the verification key. It is derived from Private when nil.
+	Public any
+}
+
+// KeySet holds the keys used to sign and verify tokens, by key identifier.
+// Tokens are signed with the active key and carry its identifier in their
+// kid header, so that keys can be rotated: a new key is added and made
+// active, while the previous one is kept until the tokens it signed have
+// expired. A KeySet is safe for concurrent use.
+type KeySet struct {
+	mu     sync.RWMutex
+	keys   map[string]*Key
+	active string
+}
+
+// NewKeySet creates a key set holding the given keys. The first key with a
+// private key is the active one.
+func NewKeySet(keys ...*Key) (*KeySet, error) {
+	s := &KeySet{keys: make(map[string]*Key)}
+	for _, key := range keys {
+		if err := s.Add(key); err != nil {
+			return nil, err
+		}
+		if s.active == "" && key.Private != nil {
+			s.active = key.ID
+		}
+	}
+
+	return s, nil
+}
+
+// Add adds or replaces a key.
+func (s *KeySet) Add(key *Key) error {
+	if key.ID == "" || key.Method == nil {
+		return errors.New("a key needs an identifier and a signing method")
+	}
+
+	k := *key
+	if k.Public == nil {
+		if k.Private == nil {
+			return fmt.Errorf("key %s has neither a private nor a public key", k.ID)
+		}
+		public, err := verificationKey(k.Private)
+		if err != nil {
+			return err
+		}
+		k.Public = public
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[k.ID] = &k
+	return nil
+}
+
+// Remove removes a key, tokens signed by it are then rejected. The active key cannot be removed.
+func (s *KeySet) Remove(kid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if kid == s.active {
+		return fmt.Errorf("key %s is the active key", kid)
+	}
+	delete(s.keys, kid)
+	return nil
+}
+
+// SetActive sets the key signing the new tokens.
+func (s *KeySet) SetActive(kid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[kid]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrKeyNotFound, kid)
+	}
+	if key.Private == nil {
+		return fmt.Errorf("key %s cannot sign, it has no private key", kid)
+	}
+
+	s.active = kid
+	return nil
+}
+
+// Active returns the key signing the new tokens.
+func (s *KeySet) Active() (*Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.keys[s.active]
+	if !ok {
+		return nil, ErrNoSigningKey
+	}
+	return key, nil
+}
+
+// Keys returns the keys, sorted by identifier.
+func (s *KeySet) Keys() []*Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]*Key, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+
+	return keys
+}
+
+// Keyfunc returns the key verifying the token, found by its kid header. The
+// tokens without kid header are verified with the active key. The token
+// algorithm must be the algorithm of the key.
+func (s *KeySet) Keyfunc(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if kid == "" {
+		kid = s.active
+	}
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, kid)
+	}
+	if t.Method.Alg() != key.Method.Alg() {
+		return nil, ErrUnSupportSigningMethod
+	}
+
+	return key.Public, nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestLoadPrivateKey(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadPrivateKey(path)
+	if err != nil {
+		t.Fatalf("LoadPrivateKey() error = %v", err)
+	}
+	if !key.Equal(loaded) {
+		t.Error("LoadPrivateKey() returned another key")
+	}
+
+	// The signing key is enough to verify the tokens it signs.
+	ctx := context.Background()
+	auth := New(nil, WithSigningMethod(jwt.SigningMethodES256), WithSigningKey(loaded))
+	token, err := auth.Sign(ctx, "user")
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if _, err := auth.ParseClaims(ctx, token.GetToken()); err != nil {
+		t.Errorf("ParseClaims() error = %v", err)
+	}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	ctx := context.Background()
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	keySet, err := NewKeySet(&Key{ID: "2024", Method: jwt.SigningMethodEdDSA, Private: edKey})
+	if err != nil {
+		t.Fatalf("NewKeySet() error = %v", err)
+	}
+	auth := New(nil, WithKeySet(keySet))
+	old, err := auth.Sign(ctx, "user")
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	if err := keySet.Add(&Key{ID: "2025", Method: jwt.SigningMethodRS256, Private: rsaKey}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if err := keySet.SetActive("2025"); err != nil {
+		t.Fatalf("SetActive() error = %v", err)
+	}
+	current, err := auth.Sign(ctx, "user")
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	for _, token := range []string{old.GetToken(), current.GetToken()} {
+		if _, err := auth.ParseClaims(ctx, token); err != nil {
+			t.Errorf("ParseClaims() error = %v", err)
+		}
+	}
+
+	// Once the old key is removed, the tokens it signed are rejected.
+	if err := keySet.Remove("2024"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if _, err := auth.ParseClaims(ctx, old.GetToken()); err == nil {
+		t.Error("ParseClaims() accepted a token signed by a removed key")
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	edPublic, _, _ := ed25519.GenerateKey(rand.Reader)
+
+	keySet, err := NewKeySet(
+		&Key{ID: "rsa", Method: jwt.SigningMethodRS256, Private: rsaKey},
+		&Key{ID: "ec", Method: jwt.SigningMethodES384, Public: &ecKey.PublicKey},
+		&Key{ID: "ed", Method: jwt.SigningMethodEdDSA, Public: edPublic},
+		&Key{ID: "hmac", Method: jwt.SigningMethodHS256, Private: []byte("secret")},
+	)
+	if err != nil {
+		t.Fatalf("NewKeySet() error = %v", err)
+	}
+
+	want := map[string]crypto.PublicKey{"rsa": &rsaKey.PublicKey, "ec": &ecKey.PublicKey, "ed": edPublic}
+	jwks := keySet.JWKS()
+	if len(jwks.Keys) != len(want) {
+		t.Fatalf("JWKS() has %d keys, want %d without the HMAC secret", len(jwks.Keys), len(want))
+	}
+	for _, jwk := range jwks.Keys {
+		key, err := jwk.PublicKey()
+		if err != nil {
+			t.Fatalf("PublicKey(%s) error = %v", jwk.Kid, err)
+		}
+		if !key.(interface{ Equal(crypto.PublicKey) bool }).Equal(want[jwk.Kid]) {
+			t.Errorf("PublicKey(%s) does not round trip", jwk.Kid)
+		}
+	}
+}
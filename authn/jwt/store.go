@@ -21,3 +21,14 @@ type Storer interface {
 	// Close the storage.
 	Close() error
 }
+
+// AtomicStorer is implemented by the storages able to store token data only
+// if it is absent in a single atomic operation. Refresh relies on it to
+// accept a refresh token only once, even when it is presented concurrently.
+type AtomicStorer interface {
+	Storer
+
+	// SetIfAbsent stores token data and specifies its expiration time, unless
+	// it is already stored. It reports whether the data was stored.
+	SetIfAbsent(ctx context.Context, key string, expiration time.Duration) (bool, error)
+}
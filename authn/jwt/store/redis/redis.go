@@ -8,21 +8,35 @@ import (
 	"time"
 
 	"github.com/redis/go-redis/v9"
+
+	"github.com/snail-plus/gopkg/authn/jwt"
 )
 
+var _ jwt.AtomicStorer = (*Store)(nil)
+
 // Config contains necessary redis options.
 type Config struct {
-	Addr     string
-	Username string
-	Password string
-	Database int
+	Addr string
+	// Addrs holds the seed addresses of a cluster, or the sentinel addresses
+	// when MasterName is set. When empty, Addr is used.
+	Addrs []string
+	// MasterName is the name of the master monitored by the sentinels.
+	MasterName string
+	// IsClusterMode creates a cluster client even when a single seed or
+	// configuration endpoint address is given. Database must be 0 in
+	// cluster mode. With MasterName, it routes read-only commands to the
+	// replicas monitored by the sentinels instead.
+	IsClusterMode bool
+	Username      string
+	Password      string
+	Database      int
 	// Sore key prefix.
 	KeyPrefix string
 }
 
 // Store redis storage.
 type Store struct {
-	cli    *redis.Client
+	cli    redis.UniversalClient
 	prefix string
 }
 
@@ -31,16 +45,24 @@ func NewStore(cfg *Config) *Store {
 	// The reason `github.com/snail-plus/gopkg/db` is not used here is
 	// to minimize dependencies, and use `github.com/redis/go-redis/v9` to
 	// create redis client is not complex.
-	cli := redis.NewClient(&redis.Options{
-		Addr:     cfg.Addr,
-		DB:       cfg.Database,
-		Username: cfg.Username,
-		Password: cfg.Password,
+	addrs := cfg.Addrs
+	if len(addrs) == 0 {
+		addrs = []string{cfg.Addr}
+	}
+
+	cli := redis.NewUniversalClient(&redis.UniversalOptions{
+		Addrs:         addrs,
+		MasterName:    cfg.MasterName,
+		IsClusterMode: cfg.IsClusterMode,
+		DB:            cfg.Database,
+		Username:      cfg.Username,
+		Password:      cfg.Password,
 	})
 	return &Store{cli: cli, prefix: cfg.KeyPrefix}
 }
 
-func NewStoreWithClient(cli *redis.Client, prefix string) *Store {
+// NewStoreWithClient create an *Store instance with an existing standalone, sentinel or cluster client.
+func NewStoreWithClient(cli redis.UniversalClient, prefix string) *Store {
 	return &Store{cli: cli, prefix: prefix}
 }
 
@@ -56,6 +78,12 @@ func (s *Store) Set(ctx context.Context, accessToken string, expiration time.Dur
 	return cmd.Err()
 }
 
+// SetIfAbsent call the Redis client to set a key-value pair with an
+// expiration time unless the key exists, and reports whether it was set.
+func (s *Store) SetIfAbsent(ctx context.Context, key string, expiration time.Duration) (bool, error) {
+	return s.cli.SetNX(ctx, s.wrapperKey(key), "1", expiration).Result()
+}
+
 // Delete delete the specified JWT Token in Redis.
 func (s *Store) Delete(ctx context.Context, accessToken string) (bool, error) {
 	cmd := s.cli.Del(ctx, s.wrapperKey(accessToken))
@@ -0,0 +1,215 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/snail-plus/gopkg/authn"
+	"github.com/snail-plus/gopkg/i18n"
+	"github.com/snail-plus/gopkg/log"
+)
+
+// maxJWKSSize bounds the size of a JWKS document.
+const maxJWKSSize = 1 << 20
+
+var _ authn.Verifier = (*JWKSVerifier)(nil)
+
+// JWKSVerifier verifies the tokens issued by an external identity provider,
+// such as an OpenID Connect provider, with the public keys of its JWKS
+// document. The document is fetched on first use and cached. It is fetched
+// again once RefreshInterval has passed, or when a token is signed by an
+// unknown key, which happens when the provider rotates its keys. Fetches are
+// rate limited by MinRefreshInterval, so that tokens with made up key
+// identifiers cannot flood the provider. When a fetch fails, the cached keys
+// are kept.
+type JWKSVerifier struct {
+	url  string
+	opts *VerifierOptions
+
+	mu   sync.RWMutex
+	keys map[string]*Key
+	// fetchedAt is the time of the last successful fetch, attemptedAt the
+	// time of the last fetch.
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	group       singleflight.Group
+}
+
+// NewJWKSVerifier creates a verifier of the tokens signed by the keys of the
+// JWKS document served at url.
+func NewJWKSVerifier(url string, options ...VerifierOption) *JWKSVerifier {
+	opts := NewVerifierOptions()
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	return &JWKSVerifier{url: url, opts: opts, keys: make(map[string]*Key)}
+}
+
+// ParseClaims parse the token and return the claims.
+func (v *JWKSVerifier) ParseClaims(ctx context.Context, accessToken string) (*jwt.RegisteredClaims, error) {
+	claims := &jwt.RegisteredClaims{}
+	if err := v.ParseClaimsInto(ctx, accessToken, claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// ParseClaimsInto parse the token and decode its claims into the given ones,
+// such as a struct embedding jwt.RegisteredClaims along with the claims of
+// the provider.
+func (v *JWKSVerifier) ParseClaimsInto(ctx context.Context, accessToken string, claims jwt.Claims) error {
+	if accessToken == "" {
+		return errors.New(i18n.FromContext(ctx).LocalizeT(MessageTokenInvalid))
+	}
+
+	parserOptions := []jwt.ParserOption{
+		jwt.WithValidMethods(v.opts.Algorithms),
+		jwt.WithLeeway(v.opts.Leeway),
+		jwt.WithExpirationRequired(),
+	}
+	if v.opts.Issuer != "" {
+		parserOptions = append(parserOptions, jwt.WithIssuer(v.opts.Issuer))
+	}
+	if v.opts.Audience != "" {
+		parserOptions = append(parserOptions, jwt.WithAudience(v.opts.Audience))
+	}
+
+	token, err := jwt.ParseWithClaims(accessToken, claims, func(t *jwt.Token) (any, error) {
+		return v.keyfunc(ctx, t)
+	}, parserOptions...)
+	if err != nil {
+		return parseError(ctx, err)
+	}
+	if !token.Valid {
+		return errors.New(i18n.FromContext(ctx).LocalizeT(MessageTokenInvalid))
+	}
+
+	return nil
+}
+
+// keyfunc returns the key verifying the token.
+func (v *JWKSVerifier) keyfunc(ctx context.Context, t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+
+	key, fresh := v.lookup(kid)
+	if key == nil || !fresh {
+		if err := v.fetch(ctx); err != nil {
+			log.C(ctx).Warnw("Failed to fetch JWKS document", "url", v.url, "err", err)
+		}
+		key, _ = v.lookup(kid)
+	}
+	if key == nil {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, kid)
+	}
+
+	if key.Method != nil && key.Method.Alg() != t.Method.Alg() {
+		return nil, ErrUnSupportSigningMethod
+	}
+
+	return key.Public, nil
+}
+
+// lookup returns the key of the given identifier, and whether the keys are
+// fresh. Without identifier, the only key of the document is returned.
+func (v *JWKSVerifier) lookup(kid string) (*Key, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	fresh := !v.fetchedAt.IsZero() && time.Since(v.fetchedAt) < v.opts.RefreshInterval
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, fresh
+		}
+	}
+
+	return v.keys[kid], fresh
+}
+
+// fetch fetches the JWKS document, unless it was fetched less than
+// MinRefreshInterval ago. Concurrent fetches are coalesced.
+func (v *JWKSVerifier) fetch(ctx context.Context) error {
+	_, err, _ := v.group.Do("", func() (any, error) {
+		v.mu.Lock()
+		if !v.attemptedAt.IsZero() && time.Since(v.attemptedAt) < v.opts.MinRefreshInterval {
+			v.mu.Unlock()
+			return nil, nil
+		}
+		v.attemptedAt = time.Now()
+		v.mu.Unlock()
+
+		// The fetch is shared by all waiting callers, so it must not be
+		// cancelled when the caller that started it gives up.
+		keys, err := v.download(context.WithoutCancel(ctx))
+		if err != nil {
+			return nil, err
+		}
+
+		v.mu.Lock()
+		v.keys, v.fetchedAt = keys, time.Now()
+		v.mu.Unlock()
+		return nil, nil
+	})
+
+	return err
+}
+
+// download fetches and decodes the JWKS document. The keys of an unsupported
+// type are skipped.
+func (v *JWKSVerifier) download(ctx context.Context) (map[string]*Key, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := v.opts.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	jwks := &JWKS{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxJWKSSize)).Decode(jwks); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS document: %w", err)
+	}
+
+	keys := make(map[string]*Key, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		public, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+
+		key := &Key{ID: jwk.Kid, Public: public}
+		if jwk.Alg != "" {
+			if !slices.Contains(v.opts.Algorithms, jwk.Alg) {
+				continue
+			}
+			key.Method = jwt.GetSigningMethod(jwk.Alg)
+		}
+		keys[jwk.Kid] = key
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package jwt
+
+import (
+	"net/http"
+	"time"
+)
+
+// VerifierOption represents a JWKS verifier option function.
+type VerifierOption func(o *VerifierOptions)
+
+// VerifierOptions represents the options for JWKS verifier configuration.
+type VerifierOptions struct {
+	// Issuer is the required iss claim, it is not checked when empty.
+	Issuer string
+	// Audience must be one of the aud claims, it is not checked when empty.
+	Audience string
+	// Leeway is the clock skew tolerated when validating exp, nbf and iat.
+	Leeway time.Duration
+	// Algorithms are the accepted signing algorithms. Symmetric algorithms
+	// are never accepted, the keys of a JWKS document are public.
+	Algorithms []string
+	// HTTPClient fetches the JWKS document.
+	HTTPClient *http.Client
+	// RefreshInterval is how long the fetched keys are used before the JWKS
+	// document is fetched again.
+	RefreshInterval time.Duration
+	// MinRefreshInterval rate limits the fetches triggered by tokens signed
+	// by an unknown key: the document is fetched at most once per interval.
+	MinRefreshInterval time.Duration
+}
+
+// VerifierWithIssuer sets the required iss claim.
+func VerifierWithIssuer(issuer string) VerifierOption {
+	return func(o *VerifierOptions) {
+		o.Issuer = issuer
+	}
+}
+
+// VerifierWithAudience sets the audience the tokens must be intended for.
+func VerifierWithAudience(audience string) VerifierOption {
+	return func(o *VerifierOptions) {
+		o.Audience = audience
+	}
+}
+
+// VerifierWithLeeway sets the tolerated clock skew.
+func VerifierWithLeeway(leeway time.Duration) VerifierOption {
+	return func(o *VerifierOptions) {
+		o.Leeway = leeway
+	}
+}
+
+// VerifierWithAlgorithms sets the accepted signing algorithms.
+func VerifierWithAlgorithms(algorithms ...string) VerifierOption {
+	return func(o *VerifierOptions) {
+		o.Algorithms = algorithms
+	}
+}
+
+// VerifierWithHTTPClient sets the client fetching the JWKS document.
+func VerifierWithHTTPClient(client *http.Client) VerifierOption {
+	return func(o *VerifierOptions) {
+		o.HTTPClient = client
+	}
+}
+
+// VerifierWithRefreshInterval sets how long the fetched keys are used.
+func VerifierWithRefreshInterval(interval time.Duration) VerifierOption {
+	return func(o *VerifierOptions) {
+		o.RefreshInterval = interval
+	}
+}
+
+// VerifierWithMinRefreshInterval sets the minimum time between two fetches of the JWKS document.
+func VerifierWithMinRefreshInterval(interval time.Duration) VerifierOption {
+	return func(o *VerifierOptions) {
+		o.MinRefreshInterval = interval
+	}
+}
+
+// NewVerifierOptions instantiates a new VerifierOptions with default values.
+func NewVerifierOptions() *VerifierOptions {
+	return &VerifierOptions{
+		Leeway:             time.Minute,
+		Algorithms:         []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"},
+		HTTPClient:         &http.Client{Timeout: 10 * time.Second},
+		RefreshInterval:    time.Hour,
+		MinRefreshInterval: 30 * time.Second,
+	}
+}
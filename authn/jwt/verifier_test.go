@@ -0,0 +1,113 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestJWKSVerifier(t *testing.T) {
+	ctx := context.Background()
+	newKey := func(kid string) *Key {
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		return &Key{ID: kid, Method: jwt.SigningMethodES256, Private: key}
+	}
+
+	keySet, _ := NewKeySet(newKey("k1"))
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		keySet.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	issuer := New(nil, WithKeySet(keySet), WithIssuer("https://idp.example.com"), WithAudience("api"))
+	verifier := NewJWKSVerifier(server.URL,
+		VerifierWithIssuer("https://idp.example.com"),
+		VerifierWithAudience("api"),
+		VerifierWithMinRefreshInterval(100*time.Millisecond),
+	)
+
+	token, _ := issuer.Sign(ctx, "user")
+	for i := 0; i < 3; i++ {
+		claims, err := verifier.ParseClaims(ctx, token.GetToken())
+		if err != nil || claims.Subject != "user" {
+			t.Fatalf("ParseClaims() = %v, %v, want the claims of user", claims, err)
+		}
+	}
+	if got := fetches.Load(); got != 1 {
+		t.Errorf("JWKS fetched %d times, want 1", got)
+	}
+
+	// A token signed by a new key triggers a fetch.
+	time.Sleep(150 * time.Millisecond)
+	_ = keySet.Add(newKey("k2"))
+	_ = keySet.SetActive("k2")
+	rotated, _ := issuer.Sign(ctx, "user")
+	if _, err := verifier.ParseClaims(ctx, rotated.GetToken()); err != nil {
+		t.Fatalf("ParseClaims() error = %v", err)
+	}
+	if got := fetches.Load(); got != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", got)
+	}
+
+	// Unknown keys do not trigger another fetch until the rate limit allows it.
+	_ = keySet.Add(newKey("k3"))
+	_ = keySet.SetActive("k3")
+	unknown, _ := issuer.Sign(ctx, "user")
+	if _, err := verifier.ParseClaims(ctx, unknown.GetToken()); err == nil {
+		t.Error("ParseClaims() accepted a token signed by an unknown key")
+	}
+	if got := fetches.Load(); got != 2 {
+		t.Errorf("JWKS fetched %d times, want the fetch to be rate limited", got)
+	}
+
+	// Tokens of other audiences are rejected.
+	other := NewJWKSVerifier(server.URL, VerifierWithIssuer("https://idp.example.com"), VerifierWithAudience("admin"))
+	if _, err := other.ParseClaims(ctx, token.GetToken()); err == nil {
+		t.Error("ParseClaims() accepted a token of another audience")
+	}
+
+	// Symmetric tokens are rejected, whatever their key.
+	hmac := New(nil, WithSigningKey([]byte("secret")))
+	symmetric, _ := hmac.Sign(ctx, "user")
+	if _, err := verifier.ParseClaims(ctx, symmetric.GetToken()); err == nil {
+		t.Error("ParseClaims() accepted an HMAC token")
+	}
+}
+
+func TestJWKSVerifierLeeway(t *testing.T) {
+	ctx := context.Background()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	keySet, _ := NewKeySet(&Key{ID: "k1", Method: jwt.SigningMethodES256, Private: key})
+	server := httptest.NewServer(keySet)
+	defer server.Close()
+
+	sign := func(expiresAt time.Time) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.RegisteredClaims{
+			Subject:   "user",
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		})
+		token.Header["kid"] = "k1"
+		s, _ := token.SignedString(key)
+		return s
+	}
+
+	verifier := NewJWKSVerifier(server.URL, VerifierWithLeeway(time.Minute))
+	if _, err := verifier.ParseClaims(ctx, sign(time.Now().Add(-30*time.Second))); err != nil {
+		t.Errorf("ParseClaims() error = %v, want the clock skew to be tolerated", err)
+	}
+	if _, err := verifier.ParseClaims(ctx, sign(time.Now().Add(-2*time.Minute))); err == nil {
+		t.Error("ParseClaims() accepted an expired token")
+	}
+}
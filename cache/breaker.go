@@ -0,0 +1,288 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/snail-plus/gopkg/cache/store"
+)
+
+// ErrBreakerOpen is returned by the deletions rejected while the breaker is open.
+var ErrBreakerOpen = errors.New("cache circuit breaker is open")
+
+// BreakerState is the state of a circuit breaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets every call through.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects every call until the cooldown is over.
+	BreakerOpen
+	// BreakerHalfOpen lets a few probe calls through to test the wrapped cache.
+	BreakerHalfOpen
+)
+
+// String returns the name of the state.
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerCache is a fail-open cache wrapper with a circuit breaker. After
+// consecutive failures of the wrapped cache, it stops calling it for a
+// cooldown period: reads are treated as misses and writes are skipped, so that
+// the callers fall back to their source of truth instead of waiting for a
+// degraded backend. Deletions fail with ErrBreakerOpen instead, since a lost
+// invalidation would serve stale values once the backend recovers.
+type BreakerCache[T any] struct {
+	opts  *BreakerOptions
+	cache Cache[T]
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	// probes and successes count the probe calls in flight and succeeded while half-open.
+	probes    int
+	successes int
+}
+
+// NewBreaker instantiates a new cache protected by a circuit breaker.
+func NewBreaker[T any](cache Cache[T], options ...BreakerOption) *BreakerCache[T] {
+	opts := NewBreakerOptions()
+	for _, opt := range options {
+		opt(opts)
+	}
+	// Without a probe, a half-open breaker would never close.
+	opts.HalfOpenProbes = max(1, opts.HalfOpenProbes)
+
+	return &BreakerCache[T]{opts: opts, cache: cache}
+}
+
+// Breaker protects a cache with a circuit breaker, see NewBreaker.
+func Breaker[T any](options ...BreakerOption) Middleware[T] {
+	return func(c Cache[T]) Cache[T] {
+		return NewBreaker(c, options...)
+	}
+}
+
+// Unwrap returns the wrapped cache.
+func (c *BreakerCache[T]) Unwrap() Cache[T] {
+	return c.cache
+}
+
+// State returns the current state of the breaker.
+func (c *BreakerCache[T]) State() BreakerState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state == BreakerOpen && time.Since(c.openedAt) >= c.opts.Cooldown {
+		return BreakerHalfOpen
+	}
+	return c.state
+}
+
+// allow reports whether a call may go through, and whether it is a probe.
+func (c *BreakerCache[T]) allow() (bool, bool) {
+	c.mu.Lock()
+	from := c.state
+	defer func() {
+		to := c.state
+		c.mu.Unlock()
+		c.notify(from, to)
+	}()
+
+	if c.state == BreakerOpen {
+		if time.Since(c.openedAt) < c.opts.Cooldown {
+			return false, false
+		}
+		c.state, c.probes, c.successes = BreakerHalfOpen, 0, 0
+	}
+
+	if c.state == BreakerClosed {
+		return true, false
+	}
+
+	if c.probes+c.successes >= c.opts.HalfOpenProbes {
+		return false, false
+	}
+	c.probes++
+	return true, true
+}
+
+// done records the outcome of a call let through.
+func (c *BreakerCache[T]) done(probe bool, err error) {
+	failure := c.opts.IsFailure(err)
+
+	c.mu.Lock()
+	from := c.state
+	defer func() {
+		to := c.state
+		c.mu.Unlock()
+		c.notify(from, to)
+	}()
+
+	if probe {
+		c.probes--
+		if c.state != BreakerHalfOpen {
+			return
+		}
+		if failure {
+			c.open()
+			return
+		}
+		c.successes++
+		if c.successes >= c.opts.HalfOpenProbes {
+			c.state, c.failures = BreakerClosed, 0
+		}
+		return
+	}
+
+	// Calls started before the breaker opened are ignored.
+	if c.state != BreakerClosed {
+		return
+	}
+	if !failure {
+		c.failures = 0
+		return
+	}
+	c.failures++
+	if c.failures >= c.opts.Threshold {
+		c.open()
+	}
+}
+
+// open opens the breaker, c.mu must be held.
+func (c *BreakerCache[T]) open() {
+	c.state, c.openedAt, c.failures = BreakerOpen, time.Now(), 0
+}
+
+// notify reports a state change, c.mu must not be held.
+func (c *BreakerCache[T]) notify(from, to BreakerState) {
+	if from != to && c.opts.OnStateChange != nil {
+		c.opts.OnStateChange(from, to)
+	}
+}
+
+// call runs fn if the breaker lets it through, otherwise it returns rejected.
+func (c *BreakerCache[T]) call(fn func() error, rejected error) error {
+	ok, probe := c.allow()
+	if !ok {
+		return rejected
+	}
+
+	err := fn()
+	c.done(probe, err)
+	return err
+}
+
+// Get returns the obj stored in cache if it exists.
+func (c *BreakerCache[T]) Get(ctx context.Context, key any) (T, error) {
+	var obj T
+	err := c.call(func() (err error) {
+		obj, err = c.cache.Get(ctx, key)
+		return err
+	}, store.ErrKeyNotFound)
+	return obj, err
+}
+
+// GetWithTTL returns the obj stored in cache and its corresponding TTL.
+func (c *BreakerCache[T]) GetWithTTL(ctx context.Context, key any) (T, time.Duration, error) {
+	var obj T
+	var ttl time.Duration
+	err := c.call(func() (err error) {
+		obj, ttl, err = c.cache.GetWithTTL(ctx, key)
+		return err
+	}, store.ErrKeyNotFound)
+	return obj, ttl, err
+}
+
+// Set populates the cache item using the given key.
+func (c *BreakerCache[T]) Set(ctx context.Context, key any, obj T) error {
+	return c.call(func() error {
+		return c.cache.Set(ctx, key, obj)
+	}, nil)
+}
+
+// SetWithTTL populates the cache item using the given key and TTL.
+func (c *BreakerCache[T]) SetWithTTL(ctx context.Context, key any, obj T, ttl time.Duration) error {
+	return c.call(func() error {
+		return c.cache.SetWithTTL(ctx, key, obj, ttl)
+	}, nil)
+}
+
+// Del removes the cache item using the given key.
+func (c *BreakerCache[T]) Del(ctx context.Context, key any) error {
+	return c.call(func() error {
+		return c.cache.Del(ctx, key)
+	}, ErrBreakerOpen)
+}
+
+// GetMany returns the objs stored in cache for all the given keys.
+func (c *BreakerCache[T]) GetMany(ctx context.Context, keys ...any) (map[any]T, error) {
+	objs := map[any]T{}
+	err := c.call(func() (err error) {
+		objs, err = c.cache.GetMany(ctx, keys...)
+		return err
+	}, nil)
+	return objs, err
+}
+
+// SetMany populates the cache items using the given keys and TTL.
+func (c *BreakerCache[T]) SetMany(ctx context.Context, items map[any]T, ttl time.Duration) error {
+	return c.call(func() error {
+		return c.cache.SetMany(ctx, items, ttl)
+	}, nil)
+}
+
+// DelMany removes the cache items using the given keys.
+func (c *BreakerCache[T]) DelMany(ctx context.Context, keys ...any) error {
+	return c.call(func() error {
+		return c.cache.DelMany(ctx, keys...)
+	}, ErrBreakerOpen)
+}
+
+// SetWithTags populates the cache item using the given key and TTL, and attaches the tags to it.
+func (c *BreakerCache[T]) SetWithTags(ctx context.Context, key any, obj T, ttl time.Duration, tags ...string) error {
+	return c.call(func() error {
+		return c.cache.SetWithTags(ctx, key, obj, ttl, tags...)
+	}, nil)
+}
+
+// InvalidateTags removes the cache items attached to any of the given tags.
+func (c *BreakerCache[T]) InvalidateTags(ctx context.Context, tags ...string) error {
+	return c.call(func() error {
+		return c.cache.InvalidateTags(ctx, tags...)
+	}, ErrBreakerOpen)
+}
+
+// DelByPrefix removes the cache items whose key starts with the given prefix.
+func (c *BreakerCache[T]) DelByPrefix(ctx context.Context, prefix string) error {
+	return c.call(func() error {
+		return c.cache.DelByPrefix(ctx, prefix)
+	}, ErrBreakerOpen)
+}
+
+// Clear resets all cache data.
+func (c *BreakerCache[T]) Clear(ctx context.Context) error {
+	return c.call(func() error {
+		return c.cache.Clear(ctx)
+	}, ErrBreakerOpen)
+}
+
+// Wait waits for all cache operations to complete.
+func (c *BreakerCache[T]) Wait(ctx context.Context) {
+	c.cache.Wait(ctx)
+}
@@ -0,0 +1,81 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/snail-plus/gopkg/cache/store"
+)
+
+// BreakerOption represents a breaker cache option function.
+type BreakerOption func(o *BreakerOptions)
+
+// BreakerOptions represents the options for breaker cache configuration.
+type BreakerOptions struct {
+	// Threshold is the number of consecutive failures that opens the breaker.
+	Threshold int
+	// Cooldown is how long the breaker stays open before letting probes through.
+	Cooldown time.Duration
+	// HalfOpenProbes is the number of calls let through while half-open, at
+	// least 1. The breaker closes once they all succeed, and opens again on
+	// the first failure.
+	HalfOpenProbes int
+	// IsFailure reports whether an error counts as a failure. By default,
+	// every error but misses and cancellations by the caller does.
+	IsFailure func(err error) bool
+	// OnStateChange is called after every state change, outside of any lock.
+	OnStateChange func(from, to BreakerState)
+}
+
+// BreakerWithThreshold sets the number of consecutive failures that opens the breaker.
+func BreakerWithThreshold(threshold int) BreakerOption {
+	return func(opts *BreakerOptions) {
+		opts.Threshold = threshold
+	}
+}
+
+// BreakerWithCooldown sets how long the breaker stays open.
+func BreakerWithCooldown(cooldown time.Duration) BreakerOption {
+	return func(opts *BreakerOptions) {
+		opts.Cooldown = cooldown
+	}
+}
+
+// BreakerWithHalfOpenProbes sets the number of calls let through while half-open.
+func BreakerWithHalfOpenProbes(probes int) BreakerOption {
+	return func(opts *BreakerOptions) {
+		opts.HalfOpenProbes = probes
+	}
+}
+
+// BreakerWithIsFailure sets the function telling which errors count as failures.
+func BreakerWithIsFailure(isFailure func(err error) bool) BreakerOption {
+	return func(opts *BreakerOptions) {
+		opts.IsFailure = isFailure
+	}
+}
+
+// BreakerWithOnStateChange sets the function called after every state change.
+func BreakerWithOnStateChange(onStateChange func(from, to BreakerState)) BreakerOption {
+	return func(opts *BreakerOptions) {
+		opts.OnStateChange = onStateChange
+	}
+}
+
+// NewBreakerOptions instantiates a new BreakerOptions with default values.
+func NewBreakerOptions() *BreakerOptions {
+	return &BreakerOptions{
+		Threshold:      5,
+		Cooldown:       10 * time.Second,
+		HalfOpenProbes: 1,
+		IsFailure:      isBreakerFailure,
+	}
+}
+
+// isBreakerFailure reports whether the error is a failure of the wrapped cache.
+func isBreakerFailure(err error) bool {
+	return err != nil && !errors.Is(err, store.ErrKeyNotFound) && !errors.Is(err, context.Canceled)
+}
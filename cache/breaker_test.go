@@ -0,0 +1,117 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/snail-plus/gopkg/cache/store"
+)
+
+// flakyCache fails every call to Get while down is set.
+type flakyCache[T any] struct {
+	Cache[T]
+	down  atomic.Bool
+	calls atomic.Int32
+}
+
+func (c *flakyCache[T]) Get(ctx context.Context, key any) (T, error) {
+	c.calls.Add(1)
+	if c.down.Load() {
+		return *new(T), errors.New("connection refused")
+	}
+	return c.Cache.Get(ctx, key)
+}
+
+func TestBreaker(t *testing.T) {
+	ctx := context.Background()
+	flaky := &flakyCache[string]{Cache: newTestCache[string]()}
+	_ = flaky.Set(ctx, "key", "value")
+
+	var changes []string
+	breaker := NewBreaker[string](flaky,
+		BreakerWithThreshold(2),
+		BreakerWithCooldown(20*time.Millisecond),
+		BreakerWithOnStateChange(func(from, to BreakerState) {
+			changes = append(changes, from.String()+">"+to.String())
+		}),
+	)
+
+	flaky.down.Store(true)
+	for i := 0; i < 2; i++ {
+		if _, err := breaker.Get(ctx, "key"); err == nil || errors.Is(err, store.ErrKeyNotFound) {
+			t.Fatalf("Get() error = %v, want the cache error", err)
+		}
+	}
+	if breaker.State() != BreakerOpen {
+		t.Fatalf("State() = %v, want open", breaker.State())
+	}
+
+	// While open, reads are misses and the wrapped cache is not called.
+	if _, err := breaker.Get(ctx, "key"); !errors.Is(err, store.ErrKeyNotFound) {
+		t.Errorf("Get() error = %v, want a miss", err)
+	}
+	if err := breaker.Set(ctx, "other", "value"); err != nil {
+		t.Errorf("Set() error = %v, want the write skipped", err)
+	}
+	// Deletions are not lost silently.
+	if err := breaker.Del(ctx, "key"); !errors.Is(err, ErrBreakerOpen) {
+		t.Errorf("Del() error = %v, want ErrBreakerOpen", err)
+	}
+	if err := breaker.InvalidateTags(ctx, "tag"); !errors.Is(err, ErrBreakerOpen) {
+		t.Errorf("InvalidateTags() error = %v, want ErrBreakerOpen", err)
+	}
+	if got := flaky.calls.Load(); got != 2 {
+		t.Errorf("wrapped cache called %d times, want 2", got)
+	}
+
+	// After the cooldown, a successful probe closes the breaker.
+	flaky.down.Store(false)
+	time.Sleep(30 * time.Millisecond)
+	if obj, err := breaker.Get(ctx, "key"); err != nil || obj != "value" {
+		t.Fatalf("Get() = %q, %v, want value", obj, err)
+	}
+	if breaker.State() != BreakerClosed {
+		t.Errorf("State() = %v, want closed", breaker.State())
+	}
+
+	want := []string{"closed>open", "open>half-open", "half-open>closed"}
+	if len(changes) != len(want) {
+		t.Fatalf("state changes = %v, want %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("state changes = %v, want %v", changes, want)
+			break
+		}
+	}
+}
+
+func TestBreakerHalfOpenProbes(t *testing.T) {
+	ctx := context.Background()
+	flaky := &flakyCache[string]{Cache: newTestCache[string]()}
+	_ = flaky.Set(ctx, "key", "value")
+
+	breaker := NewBreaker[string](flaky,
+		BreakerWithThreshold(1),
+		BreakerWithCooldown(time.Millisecond),
+		BreakerWithHalfOpenProbes(0),
+	)
+
+	flaky.down.Store(true)
+	_, _ = breaker.Get(ctx, "key")
+	flaky.down.Store(false)
+	time.Sleep(5 * time.Millisecond)
+
+	// A probe is let through even when none is configured.
+	if obj, err := breaker.Get(ctx, "key"); err != nil || obj != "value" {
+		t.Fatalf("Get() = %q, %v, want value", obj, err)
+	}
+	if breaker.State() != BreakerClosed {
+		t.Errorf("State() = %v, want closed", breaker.State())
+	}
+}
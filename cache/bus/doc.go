@@ -0,0 +1,5 @@
+// Copyright 2024 eve.  All rights reserved.
+
+// Package bus contains the invalidation buses used to keep the local level
+// of multi-level caches consistent across processes.
+package bus // import "github.com/snail-plus/gopkg/cache/bus"
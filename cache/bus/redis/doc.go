@@ -0,0 +1,3 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package redis // import "github.com/snail-plus/gopkg/cache/bus/redis"
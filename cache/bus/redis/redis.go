@@ -0,0 +1,138 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/snail-plus/gopkg/cache"
+	"github.com/snail-plus/gopkg/log"
+)
+
+const (
+	// DefaultChannel is the pub/sub channel used when none is given.
+	DefaultChannel = "gopkg:cache:invalidation"
+	// minBackoff and maxBackoff bound the delay between resubscription attempts.
+	minBackoff = 100 * time.Millisecond
+	maxBackoff = 30 * time.Second
+)
+
+var _ cache.InvalidationBus = (*Bus)(nil)
+
+// Bus is an invalidation bus over Redis pub/sub.
+type Bus struct {
+	client  redis.UniversalClient
+	channel string
+
+	mu     sync.Mutex
+	cancel []context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewBus creates a new invalidation bus publishing on the given Redis channel.
+func NewBus(client redis.UniversalClient, channel string) *Bus {
+	if channel == "" {
+		channel = DefaultChannel
+	}
+
+	return &Bus{client: client, channel: channel}
+}
+
+// Publish sends the message to every instance subscribed to the channel.
+func (b *Bus) Publish(ctx context.Context, msg *cache.InvalidationMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	return b.client.Publish(ctx, b.channel, data).Err()
+}
+
+// Subscribe calls handler for every message received on the channel until ctx
+// is done or the bus is closed. When the subscription fails it resubscribes
+// with an exponential backoff, and since messages may have been missed in the
+// meantime, handler is then asked to clear the whole local cache.
+func (b *Bus) Subscribe(ctx context.Context, handler func(msg *cache.InvalidationMessage)) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	b.mu.Lock()
+	b.cancel = append(b.cancel, cancel)
+	b.mu.Unlock()
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		b.run(ctx, handler)
+	}()
+}
+
+// run keeps a subscription alive until ctx is done.
+func (b *Bus) run(ctx context.Context, handler func(msg *cache.InvalidationMessage)) {
+	backoff := minBackoff
+	subscribed := false
+
+	for {
+		pubsub := b.client.Subscribe(ctx, b.channel)
+		if _, err := pubsub.Receive(ctx); err == nil {
+			if subscribed {
+				handler(&cache.InvalidationMessage{Clear: true})
+			}
+			subscribed = true
+			backoff = minBackoff
+
+			err = b.receive(ctx, pubsub, handler)
+			if ctx.Err() == nil {
+				log.Warnw("Cache invalidation subscription lost", "channel", b.channel, "err", err)
+			}
+		} else if ctx.Err() == nil {
+			log.Warnw("Failed to subscribe to cache invalidation channel", "channel", b.channel, "err", err)
+		}
+		_ = pubsub.Close()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+// receive dispatches the messages of a subscription until it fails.
+func (b *Bus) receive(ctx context.Context, pubsub *redis.PubSub, handler func(msg *cache.InvalidationMessage)) error {
+	for {
+		message, err := pubsub.ReceiveMessage(ctx)
+		if err != nil {
+			return err
+		}
+
+		var msg cache.InvalidationMessage
+		if err := json.Unmarshal([]byte(message.Payload), &msg); err != nil {
+			log.Warnw("Dropped malformed cache invalidation message", "channel", b.channel, "err", err)
+			continue
+		}
+
+		handler(&msg)
+	}
+}
+
+// Close stops all subscriptions and waits for them to exit. The Redis client
+// is owned by the caller and is left open.
+func (b *Bus) Close() error {
+	b.mu.Lock()
+	for _, cancel := range b.cancel {
+		cancel()
+	}
+	b.cancel = nil
+	b.mu.Unlock()
+
+	b.wg.Wait()
+
+	return nil
+}
@@ -43,6 +43,19 @@ type Cache[T any] interface {
 	GetWithTTL(ctx context.Context, key any) (T, time.Duration, error)
 	// Del deletes the object from the cache based on the given key.
 	Del(ctx context.Context, key any) error
+	// GetMany retrieves the objects of all the given keys, keys must be
+	// comparable. Keys that are not found are absent from the returned map.
+	GetMany(ctx context.Context, keys ...any) (map[any]T, error)
+	// SetMany stores all the given objects with the same time-to-live (TTL).
+	SetMany(ctx context.Context, items map[any]T, ttl time.Duration) error
+	// DelMany deletes the objects of all the given keys.
+	DelMany(ctx context.Context, keys ...any) error
+	// SetWithTags stores the object with the given key and TTL, and attaches the tags to it.
+	SetWithTags(ctx context.Context, key any, obj T, ttl time.Duration, tags ...string) error
+	// InvalidateTags deletes the objects attached to any of the given tags.
+	InvalidateTags(ctx context.Context, tags ...string) error
+	// DelByPrefix deletes the objects whose key starts with the given prefix.
+	DelByPrefix(ctx context.Context, prefix string) error
 	// Clear clears the cache.
 	Clear(ctx context.Context) error
 	// Wait waits for any pending operations to complete.
@@ -6,44 +6,76 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// ErrWriteBackDropped is reported to the chain error handler when a write-back
+// is dropped because the buffer is full.
+var ErrWriteBackDropped = errors.New("chain cache write-back buffer is full")
+
 // chainKeyValue represents the key-value pair with TTL and cache ID.
 type chainKeyValue[T any] struct {
 	key   any
 	value T
 	ttl   time.Duration
 	id    string
+	// items holds the objects of a bulk read, copied with SetMany instead of key and value.
+	items map[any]T
 }
 
 type cacheWrapper[T any] struct {
 	Cache[T]
-	id string
+	id    string
+	stats *statsCounter
 }
 
 // ChainCache represents the configuration needed by a cache aggregator.
 type ChainCache[T any] struct {
+	opts       *ChainOptions
 	caches     []*cacheWrapper[T]
 	setChannel chan *chainKeyValue[T]
+	// mu guards closed, and setChannel against being closed during a send.
+	mu     sync.RWMutex
+	closed bool
+	// closing is closed when Close is called, to release the reads blocked
+	// on a full buffer before mu is locked.
+	closing     chan struct{}
+	closingOnce sync.Once
+	wg          sync.WaitGroup
 }
 
 // NewChain instantiates a new cache aggregator.
 func NewChain[T any](caches ...Cache[T]) *ChainCache[T] {
+	return NewChainWithOptions(caches)
+}
+
+// NewChainWithOptions instantiates a new cache aggregator configured with the given options.
+func NewChainWithOptions[T any](caches []Cache[T], options ...ChainOption) *ChainCache[T] {
+	opts := NewChainOptions()
+	for _, opt := range options {
+		opt(opts)
+	}
+
 	wrappers := make([]*cacheWrapper[T], 0, len(caches))
 	for _, c := range caches {
 		wrappers = append(wrappers, &cacheWrapper[T]{
 			Cache: c,
 			id:    uuid.New().String(),
+			stats: &statsCounter{},
 		})
 	}
 	chain := &ChainCache[T]{
+		opts:       opts,
 		caches:     wrappers,
-		setChannel: make(chan *chainKeyValue[T], 10000),
+		setChannel: make(chan *chainKeyValue[T], opts.BufferSize),
+		closing:    make(chan struct{}),
 	}
 
+	chain.wg.Add(1)
 	go chain.Sync()
 
 	return chain
@@ -51,17 +83,96 @@ func NewChain[T any](caches ...Cache[T]) *ChainCache[T] {
 
 // Sync synchronizes a value in available caches, until a given cache layer.
 func (c *ChainCache[T]) Sync() {
+	defer c.wg.Done()
+
 	for item := range c.setChannel {
-		for _, cache := range c.caches {
+		for i, cache := range c.caches {
 			if item.id == cache.id {
 				break
 			}
 
-			cache.SetWithTTL(context.Background(), item.key, item.value, item.ttl)
+			c.writeBack(i, cache, item)
+		}
+	}
+}
+
+// writeBack copies the item to the layer of the given index.
+func (c *ChainCache[T]) writeBack(layer int, cache *cacheWrapper[T], item *chainKeyValue[T]) {
+	var err error
+	switch {
+	case item.items == nil:
+		err = cache.SetWithTTL(context.Background(), item.key, item.value, c.opts.layerTTL(layer, item.ttl))
+	case c.opts.LayerMaxTTL[layer] > 0:
+		err = cache.SetMany(context.Background(), item.items, c.opts.LayerMaxTTL[layer])
+	default:
+		return
+	}
+
+	cache.stats.set(err)
+	if err != nil {
+		c.reportError(item, fmt.Errorf("unable to write back item into cache %d: %w", layer, err))
+	}
+}
+
+// enqueue schedules the copy of the item to the layers above the one it was
+// read from. Under WriteBackBlock, it waits for room in the buffer until ctx is
+// done or the chain is closed.
+func (c *ChainCache[T]) enqueue(ctx context.Context, item *chainKeyValue[T]) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return
+	}
+
+	if c.opts.Policy == WriteBackBlock {
+		select {
+		case c.setChannel <- item:
+		case <-ctx.Done():
+			c.reportError(item, ctx.Err())
+		case <-c.closing:
 		}
+		return
+	}
+
+	select {
+	case c.setChannel <- item:
+	default:
+		c.reportError(item, ErrWriteBackDropped)
 	}
 }
 
+// reportError passes a write-back error to the error handler.
+func (c *ChainCache[T]) reportError(item *chainKeyValue[T], err error) {
+	if c.opts.OnError == nil {
+		return
+	}
+
+	if item.items == nil {
+		c.opts.OnError(item.key, err)
+		return
+	}
+	for key := range item.items {
+		c.opts.OnError(key, err)
+	}
+}
+
+// Close stops copying objects to the upper layers, once the pending
+// write-backs are done. Reads remain possible but no longer copy objects.
+func (c *ChainCache[T]) Close() error {
+	c.closingOnce.Do(func() { close(c.closing) })
+
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.setChannel)
+	}
+	c.mu.Unlock()
+
+	c.wg.Wait()
+	return nil
+}
+
 // Get returns the obj stored in cache if it exists.
 func (c *ChainCache[T]) Get(ctx context.Context, key any) (T, error) {
 	obj, _, err := c.GetWithTTL(ctx, key)
@@ -76,9 +187,12 @@ func (c *ChainCache[T]) GetWithTTL(ctx context.Context, key any) (T, time.Durati
 
 	for _, cache := range c.caches {
 		obj, ttl, err = cache.GetWithTTL(ctx, key)
+		cache.stats.get(err)
 		if err == nil {
 			// Set the value back until this cache layer.
-			c.setChannel <- &chainKeyValue[T]{key, obj, ttl, cache.id}
+			if cache != c.caches[0] {
+				c.enqueue(ctx, &chainKeyValue[T]{key: key, value: obj, ttl: ttl, id: cache.id})
+			}
 			return obj, ttl, nil
 		}
 	}
@@ -90,51 +204,153 @@ func (c *ChainCache[T]) GetWithTTL(ctx context.Context, key any) (T, time.Durati
 func (c *ChainCache[T]) Set(ctx context.Context, key any, obj T) error {
 	errs := []error{}
 	for _, cache := range c.caches {
-		if err := cache.Set(ctx, key, obj); err != nil {
+		err := cache.Set(ctx, key, obj)
+		cache.stats.set(err)
+		if err != nil {
 			errs = append(errs, fmt.Errorf("unable to set item into cache %w", err))
 		}
 	}
 
-	if len(errs) == 0 {
-		return nil
-	}
-
-	errStr := ""
-	for k, v := range errs {
-		errStr += fmt.Sprintf("error %d of %d: %v", k+1, len(errs), v.Error())
-	}
-	return errors.New(errStr)
+	return joinErrors(errs)
 }
 
 // SetWithTTL sets a value in available caches with a specified TTL.
 func (c *ChainCache[T]) SetWithTTL(ctx context.Context, key any, obj T, ttl time.Duration) error {
 	errs := []error{}
 	for _, cache := range c.caches {
-		if err := cache.SetWithTTL(ctx, key, obj, ttl); err != nil {
+		err := cache.SetWithTTL(ctx, key, obj, ttl)
+		cache.stats.set(err)
+		if err != nil {
 			errs = append(errs, fmt.Errorf("unable to set item into cache: %w", err))
 		}
 	}
 
-	if len(errs) == 0 {
-		return nil
+	return joinErrors(errs)
+}
+
+// Del removes a value from all available caches.
+func (c *ChainCache[T]) Del(ctx context.Context, key any) error {
+	for _, cache := range c.caches {
+		cache.stats.del(cache.Del(ctx, key))
 	}
 
-	errStr := ""
-	for k, v := range errs {
-		errStr += fmt.Sprintf("error %d of %d: %v", k+1, len(errs), v.Error())
+	return nil
+}
+
+// GetMany returns the objects of the given keys. Every cache layer is only
+// asked for the keys that were not found in the layers before it.
+//
+// Objects found in a lower layer are only copied to the upper layers that have
+// a max TTL, see ChainWithLayerMaxTTL, since bulk reads do not return the TTLs
+// of the objects.
+func (c *ChainCache[T]) GetMany(ctx context.Context, keys ...any) (map[any]T, error) {
+	objs := make(map[any]T, len(keys))
+	missing := keys
+
+	var err error
+	for _, cache := range c.caches {
+		if len(missing) == 0 {
+			break
+		}
+
+		var found map[any]T
+		found, err = cache.GetMany(ctx, missing...)
+		cache.stats.getMany(len(missing), len(found), err)
+		if err != nil {
+			continue
+		}
+
+		remaining := make([]any, 0, len(missing)-len(found))
+		for _, key := range missing {
+			if obj, ok := found[key]; ok {
+				objs[key] = obj
+				continue
+			}
+			remaining = append(remaining, key)
+		}
+		missing = remaining
+
+		if len(found) > 0 && cache != c.caches[0] && len(c.opts.LayerMaxTTL) > 0 {
+			c.enqueue(ctx, &chainKeyValue[T]{id: cache.id, items: found})
+		}
 	}
-	return errors.New(errStr)
+
+	// A failing layer is not fatal as long as another layer had every key.
+	if len(missing) == 0 {
+		return objs, nil
+	}
+
+	return objs, err
 }
 
-// Del removes a value from all available caches.
-func (c *ChainCache[T]) Del(ctx context.Context, key any) error {
+// SetMany sets values in available caches with a specified TTL.
+func (c *ChainCache[T]) SetMany(ctx context.Context, items map[any]T, ttl time.Duration) error {
+	errs := []error{}
+	for _, cache := range c.caches {
+		err := cache.SetMany(ctx, items, ttl)
+		cache.stats.set(err)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to set items into cache: %w", err))
+		}
+	}
+
+	return joinErrors(errs)
+}
+
+// DelMany removes values from all available caches.
+func (c *ChainCache[T]) DelMany(ctx context.Context, keys ...any) error {
 	for _, cache := range c.caches {
-		cache.Del(ctx, key)
+		cache.stats.del(cache.DelMany(ctx, keys...))
 	}
 
 	return nil
 }
 
+// SetWithTags sets a value in available caches with a specified TTL and attaches the tags to it.
+//
+// Values copied to upper layers by Sync do not carry their tags, so a tag
+// invalidation leaves those copies in place until they expire.
+func (c *ChainCache[T]) SetWithTags(ctx context.Context, key any, obj T, ttl time.Duration, tags ...string) error {
+	errs := []error{}
+	for _, cache := range c.caches {
+		err := cache.SetWithTags(ctx, key, obj, ttl, tags...)
+		cache.stats.set(err)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to set item into cache: %w", err))
+		}
+	}
+
+	return joinErrors(errs)
+}
+
+// InvalidateTags removes the values attached to any of the given tags from all available caches.
+func (c *ChainCache[T]) InvalidateTags(ctx context.Context, tags ...string) error {
+	errs := []error{}
+	for _, cache := range c.caches {
+		err := cache.InvalidateTags(ctx, tags...)
+		cache.stats.del(err)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to invalidate tags in cache: %w", err))
+		}
+	}
+
+	return joinErrors(errs)
+}
+
+// DelByPrefix removes the values whose key starts with the given prefix from all available caches.
+func (c *ChainCache[T]) DelByPrefix(ctx context.Context, prefix string) error {
+	errs := []error{}
+	for _, cache := range c.caches {
+		err := cache.DelByPrefix(ctx, prefix)
+		cache.stats.del(err)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to delete items by prefix from cache: %w", err))
+		}
+	}
+
+	return joinErrors(errs)
+}
+
 // Clear resets all cache data.
 func (c *ChainCache[T]) Clear(ctx context.Context) error {
 	for _, cache := range c.caches {
@@ -144,9 +360,32 @@ func (c *ChainCache[T]) Clear(ctx context.Context) error {
 	return nil
 }
 
+// LayerStats returns the statistics of every cache layer, in chain order.
+func (c *ChainCache[T]) LayerStats() []LayerStats {
+	stats := make([]LayerStats, 0, len(c.caches))
+	for i, cache := range c.caches {
+		stats = append(stats, LayerStats{Layer: strconv.Itoa(i), Stats: cache.stats.snapshot()})
+	}
+
+	return stats
+}
+
 // Wait waits for all cache operations to complete.
 func (c *ChainCache[T]) Wait(ctx context.Context) {
 	for _, cache := range c.caches {
 		cache.Wait(ctx)
 	}
 }
+
+// joinErrors combines the errors of every cache layer into one error.
+func joinErrors(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	errStr := ""
+	for k, v := range errs {
+		errStr += fmt.Sprintf("error %d of %d: %v", k+1, len(errs), v.Error())
+	}
+	return errors.New(errStr)
+}
@@ -0,0 +1,88 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package cache
+
+import (
+	"time"
+)
+
+// WriteBackPolicy tells what a chain cache does with a write-back when its buffer is full.
+type WriteBackPolicy int
+
+const (
+	// WriteBackDrop drops the write-back and reports ErrWriteBackDropped to
+	// the error handler. This is the default and the zero value, so that a
+	// slow upper layer does not slow down the reads.
+	WriteBackDrop WriteBackPolicy = iota
+	// WriteBackBlock makes the read wait until there is room in the buffer,
+	// its context is done or the chain is closed.
+	WriteBackBlock
+)
+
+// ChainOption represents a chain cache option function.
+type ChainOption func(o *ChainOptions)
+
+// ChainOptions represents the options for chain cache configuration.
+type ChainOptions struct {
+	// BufferSize is the number of write-backs waiting to be copied to the
+	// upper layers. An object read from a lower layer is copied to the layers
+	// above it in the background.
+	BufferSize int
+	// Policy tells what to do with a write-back when the buffer is full.
+	Policy WriteBackPolicy
+	// OnError is called from the background goroutine when a write-back fails
+	// or is dropped.
+	OnError func(key any, err error)
+	// LayerMaxTTL bounds the TTL of the objects copied to a layer, by layer
+	// index. Objects without a TTL are copied with the max TTL. The bulk reads
+	// of GetMany, which do not return TTLs, are only copied to the layers with
+	// a max TTL.
+	LayerMaxTTL map[int]time.Duration
+}
+
+// ChainWithBufferSize sets the number of pending write-backs.
+func ChainWithBufferSize(size int) ChainOption {
+	return func(opts *ChainOptions) {
+		opts.BufferSize = size
+	}
+}
+
+// ChainWithPolicy sets what to do with a write-back when the buffer is full.
+func ChainWithPolicy(policy WriteBackPolicy) ChainOption {
+	return func(opts *ChainOptions) {
+		opts.Policy = policy
+	}
+}
+
+// ChainWithErrorHandler sets the function called when a write-back fails or is dropped.
+func ChainWithErrorHandler(onError func(key any, err error)) ChainOption {
+	return func(opts *ChainOptions) {
+		opts.OnError = onError
+	}
+}
+
+// ChainWithLayerMaxTTL sets the maximum TTL of the objects copied to the layer of the given index.
+func ChainWithLayerMaxTTL(layer int, ttl time.Duration) ChainOption {
+	return func(opts *ChainOptions) {
+		opts.LayerMaxTTL[layer] = ttl
+	}
+}
+
+// NewChainOptions instantiates a new ChainOptions with default values.
+func NewChainOptions() *ChainOptions {
+	return &ChainOptions{
+		BufferSize:  10000,
+		Policy:      WriteBackDrop,
+		LayerMaxTTL: make(map[int]time.Duration),
+	}
+}
+
+// layerTTL returns the TTL an object expiring after ttl, zero meaning never,
+// is copied to the given layer with.
+func (o *ChainOptions) layerTTL(layer int, ttl time.Duration) time.Duration {
+	if maxTTL := o.LayerMaxTTL[layer]; maxTTL > 0 && (ttl <= 0 || maxTTL < ttl) {
+		return maxTTL
+	}
+
+	return ttl
+}
@@ -0,0 +1,122 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestChainWriteBack(t *testing.T) {
+	ctx := context.Background()
+	upper, lower := newTestCache[string](), newTestCache[string]()
+	chain := NewChainWithOptions([]Cache[string]{upper, lower}, ChainWithLayerMaxTTL(0, time.Minute))
+
+	if err := lower.Set(ctx, "a", "value-a"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := lower.SetWithTTL(ctx, "b", "value-b", time.Hour); err != nil {
+		t.Fatalf("SetWithTTL() error = %v", err)
+	}
+
+	if obj, err := chain.Get(ctx, "a"); err != nil || obj != "value-a" {
+		t.Fatalf("Get() = %q, %v, want value-a", obj, err)
+	}
+	if objs, err := chain.GetMany(ctx, "b", "c"); err != nil || len(objs) != 1 || objs["b"] != "value-b" {
+		t.Fatalf("GetMany() = %v, %v, want b", objs, err)
+	}
+
+	// Close drains the pending write-backs.
+	if err := chain.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	for _, key := range []string{"a", "b"} {
+		_, ttl, err := upper.GetWithTTL(ctx, key)
+		if err != nil {
+			t.Fatalf("GetWithTTL(%q) error = %v, want it written back", key, err)
+		}
+		if ttl <= 0 || ttl > time.Minute {
+			t.Errorf("GetWithTTL(%q) ttl = %v, want at most the layer max TTL", key, ttl)
+		}
+	}
+
+	// Reads after Close no longer write back.
+	if obj, err := chain.Get(ctx, "a"); err != nil || obj != "value-a" {
+		t.Errorf("Get() after Close = %q, %v, want value-a", obj, err)
+	}
+}
+
+// blockingCache blocks the writes until release is closed.
+type blockingCache[T any] struct {
+	Cache[T]
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (c *blockingCache[T]) SetWithTTL(ctx context.Context, key any, obj T, ttl time.Duration) error {
+	c.entered <- struct{}{}
+	<-c.release
+	return c.Cache.SetWithTTL(ctx, key, obj, ttl)
+}
+
+func TestChainWriteBackBlock(t *testing.T) {
+	ctx := context.Background()
+	upper := &blockingCache[string]{Cache: newTestCache[string](), entered: make(chan struct{}, 10), release: make(chan struct{})}
+	lower := newTestCache[string]()
+	var errs []error
+	chain := NewChainWithOptions([]Cache[string]{upper, lower},
+		ChainWithBufferSize(1),
+		ChainWithPolicy(WriteBackBlock),
+		ChainWithErrorHandler(func(_ any, err error) { errs = append(errs, err) }),
+	)
+
+	if err := lower.Set(ctx, "a", "value-a"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	// The first write-back blocks the upper layer, the second fills the buffer.
+	if _, err := chain.Get(ctx, "a"); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	<-upper.entered
+	if _, err := chain.Get(ctx, "a"); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	// A read waiting for room in the buffer gives up with its context.
+	timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if obj, err := chain.Get(timeoutCtx, "a"); err != nil || obj != "value-a" {
+		t.Fatalf("Get() = %q, %v, want value-a", obj, err)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], context.DeadlineExceeded) {
+		t.Errorf("reported errors = %v, want the context deadline", errs)
+	}
+
+	// Close releases the reads waiting for room in the buffer.
+	blocked := make(chan struct{})
+	go func() {
+		defer close(blocked)
+		_, _ = chain.Get(ctx, "a")
+	}()
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		_ = chain.Close()
+	}()
+	select {
+	case <-blocked:
+	case <-time.After(time.Second):
+		t.Fatal("Get() still blocked after Close()")
+	}
+
+	close(upper.release)
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close() did not return")
+	}
+}
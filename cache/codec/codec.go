@@ -0,0 +1,77 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package codec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+var (
+	// JSON encodes objects with encoding/json.
+	JSON Codec = jsonCodec{}
+	// Msgpack encodes objects in the compact MessagePack binary format.
+	Msgpack Codec = msgpackCodec{}
+	// Gob encodes objects with encoding/gob.
+	Gob Codec = gobCodec{}
+)
+
+// Codec converts objects to and from the bytes kept in a store.
+type Codec interface {
+	// Marshal encodes the given object.
+	Marshal(v any) ([]byte, error)
+	// Unmarshal decodes data into the object pointed to by v.
+	Unmarshal(data []byte, v any) error
+	// Name returns the name of the codec.
+	Name() string
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Marshal(v any) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v any) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+func (msgpackCodec) Name() string {
+	return "msgpack"
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+func (gobCodec) Name() string {
+	return "gob"
+}
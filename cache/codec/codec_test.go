@@ -0,0 +1,42 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package codec
+
+import (
+	"reflect"
+	"testing"
+)
+
+type profile struct {
+	ID    int64
+	Name  string
+	Roles []string
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	codecs := []Codec{
+		JSON,
+		Msgpack,
+		Gob,
+		NewCompressed(JSON, Gzip),
+		NewCompressed(Msgpack, Zstd),
+	}
+
+	want := profile{ID: 42, Name: "colin", Roles: []string{"admin", "dev"}}
+	for _, c := range codecs {
+		t.Run(c.Name(), func(t *testing.T) {
+			data, err := c.Marshal(want)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+
+			var got profile
+			if err := c.Unmarshal(data, &got); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Unmarshal() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package codec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	// Gzip compresses data with compress/gzip at the default level.
+	Gzip Compressor = gzipCompressor{level: gzip.DefaultCompression}
+	// Zstd compresses data with Zstandard at the default level.
+	Zstd Compressor = newZstdCompressor()
+)
+
+// Compressor compresses the output of a codec.
+type Compressor interface {
+	// Compress compresses the given data.
+	Compress(data []byte) ([]byte, error)
+	// Decompress restores data compressed with Compress.
+	Decompress(data []byte) ([]byte, error)
+	// Name returns the name of the compression algorithm.
+	Name() string
+}
+
+// compressed is a codec that compresses the output of another codec.
+type compressed struct {
+	codec      Codec
+	compressor Compressor
+}
+
+// NewCompressed returns a codec that compresses the output of the given codec.
+func NewCompressed(codec Codec, compressor Compressor) Codec {
+	return &compressed{codec: codec, compressor: compressor}
+}
+
+func (c *compressed) Marshal(v any) ([]byte, error) {
+	data, err := c.codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.compressor.Compress(data)
+}
+
+func (c *compressed) Unmarshal(data []byte, v any) error {
+	data, err := c.compressor.Decompress(data)
+	if err != nil {
+		return err
+	}
+
+	return c.codec.Unmarshal(data, v)
+}
+
+func (c *compressed) Name() string {
+	return c.codec.Name() + "+" + c.compressor.Name()
+}
+
+type gzipCompressor struct {
+	level int
+}
+
+// NewGzip returns a gzip compressor using the given compression level.
+func NewGzip(level int) Compressor {
+	return gzipCompressor{level: level}
+}
+
+func (c gzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, c.level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (c gzipCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+func (c gzipCompressor) Name() string {
+	return "gzip"
+}
+
+type zstdCompressor struct {
+	once    sync.Once
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+}
+
+func newZstdCompressor() *zstdCompressor {
+	return &zstdCompressor{}
+}
+
+// init creates the encoder and decoder on first use, both are safe for
+// concurrent use through EncodeAll and DecodeAll.
+func (c *zstdCompressor) init() {
+	c.once.Do(func() {
+		// This won't return an error because we're passing valid parameters
+		c.encoder, _ = zstd.NewWriter(nil)
+		c.decoder, _ = zstd.NewReader(nil)
+	})
+}
+
+func (c *zstdCompressor) Compress(data []byte) ([]byte, error) {
+	c.init()
+	return c.encoder.EncodeAll(data, nil), nil
+}
+
+func (c *zstdCompressor) Decompress(data []byte) ([]byte, error) {
+	c.init()
+	return c.decoder.DecodeAll(data, nil)
+}
+
+func (c *zstdCompressor) Name() string {
+	return "zstd"
+}
@@ -0,0 +1,5 @@
+// Copyright 2024 eve.  All rights reserved.
+
+// Package codec provides the serialization used by caches whose stores only
+// hold bytes, such as Redis.
+package codec // import "github.com/snail-plus/gopkg/cache/codec"
@@ -4,20 +4,51 @@ package cache
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"reflect"
 	"time"
 
+	"github.com/snail-plus/gopkg/cache/codec"
 	"github.com/snail-plus/gopkg/cache/store"
 )
 
+// ErrTypeMismatch is returned when a value read from the store cannot be converted to the cache type.
+var ErrTypeMismatch = errors.New("cached value type mismatch")
+
+// DelegateOption represents a delegate cache option function.
+type DelegateOption func(o *DelegateOptions)
+
+// DelegateOptions represents the options for delegate cache configuration.
+type DelegateOptions struct {
+	// Codec encodes objects before they are written to the store and decodes
+	// them after they are read. Stores that only hold bytes, such as Redis,
+	// need a codec to cache anything other than strings and byte slices.
+	Codec codec.Codec
+}
+
+// DelegateWithCodec sets the codec used to serialize objects in the store.
+func DelegateWithCodec(c codec.Codec) DelegateOption {
+	return func(opts *DelegateOptions) {
+		opts.Codec = c
+	}
+}
+
 // DelegateCache is a representative cache used to represent the store. By representing
 // the store, different stores can be encapsulated into a unified cache and perform some unified operations.
 type DelegateCache[T any] struct {
+	opts  *DelegateOptions
 	store store.Store
 }
 
 // New instantiates a new delegate cache entry.
-func New[T any](store store.Store) *DelegateCache[T] {
-	return &DelegateCache[T]{store: store}
+func New[T any](store store.Store, options ...DelegateOption) *DelegateCache[T] {
+	opts := &DelegateOptions{}
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	return &DelegateCache[T]{opts: opts, store: store}
 }
 
 // Get returns the obj stored in cache if it exists.
@@ -27,11 +58,7 @@ func (c *DelegateCache[T]) Get(ctx context.Context, key any) (T, error) {
 		return *new(T), err
 	}
 
-	if v, ok := value.(T); ok {
-		return v, nil
-	}
-
-	return *new(T), nil
+	return c.decode(value)
 }
 
 // GetWithTTL returns the obj stored in cache and its corresponding TTL.
@@ -41,21 +68,28 @@ func (c *DelegateCache[T]) GetWithTTL(ctx context.Context, key any) (T, time.Dur
 		return *new(T), duration, err
 	}
 
-	if v, ok := value.(T); ok {
-		return v, duration, nil
-	}
-
-	return *new(T), duration, nil
+	obj, err := c.decode(value)
+	return obj, duration, err
 }
 
 // Set populates the cache item using the given key.
 func (c *DelegateCache[T]) Set(ctx context.Context, key any, obj T) error {
-	return c.store.Set(ctx, keyFunc(key), obj)
+	value, err := c.encode(obj)
+	if err != nil {
+		return err
+	}
+
+	return c.store.Set(ctx, keyFunc(key), value)
 }
 
 // SetWithTTL populates the cache item using the given key with a specified TTL.
 func (c *DelegateCache[T]) SetWithTTL(ctx context.Context, key any, obj T, ttl time.Duration) error {
-	return c.store.SetWithTTL(ctx, keyFunc(key), obj, ttl)
+	value, err := c.encode(obj)
+	if err != nil {
+		return err
+	}
+
+	return c.store.SetWithTTL(ctx, keyFunc(key), value, ttl)
 }
 
 // Del removes the cache item using the given key.
@@ -63,6 +97,79 @@ func (c *DelegateCache[T]) Del(ctx context.Context, key any) error {
 	return c.store.Del(ctx, keyFunc(key))
 }
 
+// GetMany returns the objs stored in cache for all the given keys. A value
+// that cannot be decoded, such as one written by an older version of T, is
+// treated as a miss instead of failing the other keys.
+func (c *DelegateCache[T]) GetMany(ctx context.Context, keys ...any) (map[any]T, error) {
+	storeKeys := make([]any, 0, len(keys))
+	byStoreKey := make(map[string]any, len(keys))
+	for _, key := range keys {
+		k := keyFunc(key)
+		storeKeys = append(storeKeys, k)
+		byStoreKey[k] = key
+	}
+
+	values, err := c.store.GetMany(ctx, storeKeys...)
+	if err != nil {
+		return nil, err
+	}
+
+	objs := make(map[any]T, len(values))
+	for k, value := range values {
+		obj, err := c.decode(value)
+		if err != nil {
+			continue
+		}
+		objs[byStoreKey[k.(string)]] = obj
+	}
+
+	return objs, nil
+}
+
+// SetMany populates the cache items using the given keys with a specified TTL.
+func (c *DelegateCache[T]) SetMany(ctx context.Context, items map[any]T, ttl time.Duration) error {
+	values := make(map[any]any, len(items))
+	for key, obj := range items {
+		value, err := c.encode(obj)
+		if err != nil {
+			return err
+		}
+		values[keyFunc(key)] = value
+	}
+
+	return c.store.SetMany(ctx, values, ttl)
+}
+
+// DelMany removes the cache items using the given keys.
+func (c *DelegateCache[T]) DelMany(ctx context.Context, keys ...any) error {
+	storeKeys := make([]any, 0, len(keys))
+	for _, key := range keys {
+		storeKeys = append(storeKeys, keyFunc(key))
+	}
+
+	return c.store.DelMany(ctx, storeKeys...)
+}
+
+// SetWithTags populates the cache item using the given key and TTL, and attaches the tags to it.
+func (c *DelegateCache[T]) SetWithTags(ctx context.Context, key any, obj T, ttl time.Duration, tags ...string) error {
+	value, err := c.encode(obj)
+	if err != nil {
+		return err
+	}
+
+	return c.store.SetWithTags(ctx, keyFunc(key), value, ttl, tags...)
+}
+
+// InvalidateTags removes the cache items attached to any of the given tags.
+func (c *DelegateCache[T]) InvalidateTags(ctx context.Context, tags ...string) error {
+	return c.store.InvalidateTags(ctx, tags...)
+}
+
+// DelByPrefix removes the cache items whose key starts with the given prefix.
+func (c *DelegateCache[T]) DelByPrefix(ctx context.Context, prefix string) error {
+	return c.store.DelByPrefix(ctx, prefix)
+}
+
 // Clear resets all cache data.
 func (c *DelegateCache[T]) Clear(ctx context.Context) error {
 	return c.store.Clear(ctx)
@@ -72,3 +179,61 @@ func (c *DelegateCache[T]) Clear(ctx context.Context) error {
 func (c *DelegateCache[T]) Wait(ctx context.Context) {
 	c.store.Wait(ctx)
 }
+
+// encode converts the object into the value written to the store.
+func (c *DelegateCache[T]) encode(obj T) (any, error) {
+	if c.opts.Codec == nil {
+		return obj, nil
+	}
+
+	data, err := c.opts.Codec.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode object with %s codec: %w", c.opts.Codec.Name(), err)
+	}
+
+	return data, nil
+}
+
+// decode converts a value read from the store into the cache type.
+func (c *DelegateCache[T]) decode(value any) (T, error) {
+	var obj T
+
+	if c.opts.Codec != nil {
+		var data []byte
+		switch typed := value.(type) {
+		case []byte:
+			data = typed
+		case string:
+			data = []byte(typed)
+		default:
+			return obj, fmt.Errorf("%w: unable to decode %T with %s codec", ErrTypeMismatch, value, c.opts.Codec.Name())
+		}
+
+		if err := c.opts.Codec.Unmarshal(data, &obj); err != nil {
+			return obj, fmt.Errorf("unable to decode object with %s codec: %w", c.opts.Codec.Name(), err)
+		}
+		return obj, nil
+	}
+
+	if value == nil {
+		return obj, nil
+	}
+
+	if v, ok := value.(T); ok {
+		return v, nil
+	}
+
+	// Byte oriented stores return strings for byte slices and the other way around.
+	switch typed := value.(type) {
+	case string:
+		if v, ok := any([]byte(typed)).(T); ok {
+			return v, nil
+		}
+	case []byte:
+		if v, ok := any(string(typed)).(T); ok {
+			return v, nil
+		}
+	}
+
+	return obj, fmt.Errorf("%w: got %T, want %v", ErrTypeMismatch, value, reflect.TypeOf((*T)(nil)).Elem())
+}
@@ -0,0 +1,99 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/snail-plus/gopkg/cache/codec"
+	"github.com/snail-plus/gopkg/cache/store/gocache"
+)
+
+type testProfile struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestDelegateCodec(t *testing.T) {
+	client := newFakeGoCache()
+	c := New[testProfile](gocache.NewGoCache(client), DelegateWithCodec(codec.JSON))
+
+	ctx := context.Background()
+	want := testProfile{ID: 1, Name: "colin"}
+	if err := c.Set(ctx, "profile", want); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	// Simulate a byte oriented store such as Redis, which returns strings.
+	raw, _ := client.Get("profile")
+	client.Set("profile", string(raw.([]byte)), 0)
+
+	got, err := c.Get(ctx, "profile")
+	if err != nil || got != want {
+		t.Fatalf("Get() = %+v, %v, want %+v", got, err, want)
+	}
+}
+
+func TestDelegateTypeMismatch(t *testing.T) {
+	client := newFakeGoCache()
+	client.Set("profile", `{"id":1}`, 0)
+	c := New[testProfile](gocache.NewGoCache(client))
+
+	if _, err := c.Get(context.Background(), "profile"); !errors.Is(err, ErrTypeMismatch) {
+		t.Fatalf("Get() error = %v, want %v", err, ErrTypeMismatch)
+	}
+}
+
+func TestDelegateGetManyUndecodable(t *testing.T) {
+	client := newFakeGoCache()
+	c := New[testProfile](gocache.NewGoCache(client), DelegateWithCodec(codec.JSON))
+
+	ctx := context.Background()
+	want := testProfile{ID: 1, Name: "colin"}
+	if err := c.Set(ctx, "good", want); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	client.Set("bad", []byte("not json"), 0)
+
+	objs, err := c.GetMany(ctx, "good", "bad")
+	if err != nil {
+		t.Fatalf("GetMany() error = %v", err)
+	}
+	if len(objs) != 1 || objs["good"] != want {
+		t.Errorf("GetMany() = %+v, want only the decodable key", objs)
+	}
+}
+
+func TestDelegateTagsAndPrefix(t *testing.T) {
+	c := newTestCache[string]()
+
+	ctx := context.Background()
+	_ = c.SetWithTags(ctx, "user:1:profile", "profile", 0, "user:1")
+	_ = c.SetWithTags(ctx, "user:1:feed", "feed", 0, "user:1")
+	_ = c.SetWithTags(ctx, "user:2:profile", "profile", 0, "user:2")
+	_ = c.Set(ctx, "order:1", "order")
+
+	if err := c.InvalidateTags(ctx, "user:1"); err != nil {
+		t.Fatalf("InvalidateTags() error = %v", err)
+	}
+	for _, key := range []string{"user:1:profile", "user:1:feed"} {
+		if _, err := c.Get(ctx, key); err == nil {
+			t.Errorf("Get(%q) found an invalidated item", key)
+		}
+	}
+	if _, err := c.Get(ctx, "user:2:profile"); err != nil {
+		t.Errorf("Get(%q) error = %v", "user:2:profile", err)
+	}
+
+	if err := c.DelByPrefix(ctx, "user:"); err != nil {
+		t.Fatalf("DelByPrefix() error = %v", err)
+	}
+	if _, err := c.Get(ctx, "user:2:profile"); err == nil {
+		t.Errorf("Get(%q) found a deleted item", "user:2:profile")
+	}
+	if _, err := c.Get(ctx, "order:1"); err != nil {
+		t.Errorf("Get(%q) error = %v", "order:1", err)
+	}
+}
@@ -0,0 +1,28 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package cache
+
+import (
+	"context"
+)
+
+// InvalidationMessage tells other cache instances which of their local entries are stale.
+type InvalidationMessage struct {
+	// Source is the ID of the instance that published the message. Instances
+	// ignore the messages they published themselves.
+	Source string `json:"source"`
+	// Keys are the cache keys to evict from the local cache.
+	Keys []string `json:"keys,omitempty"`
+	// Clear asks the receivers to clear their whole local cache.
+	Clear bool `json:"clear,omitempty"`
+}
+
+// InvalidationBus broadcasts invalidation messages between the instances of a
+// multi-level cache running in different processes.
+type InvalidationBus interface {
+	// Publish sends the message to every subscribed instance.
+	Publish(ctx context.Context, msg *InvalidationMessage) error
+	// Subscribe calls handler for every message received until ctx is done.
+	// It does not block, and implementations keep resubscribing after failures.
+	Subscribe(ctx context.Context, handler func(msg *InvalidationMessage))
+}
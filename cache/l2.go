@@ -4,21 +4,30 @@ package cache
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/dgraph-io/ristretto"
+	"github.com/google/uuid"
 
 	"github.com/snail-plus/gopkg/cache/store"
 )
 
 // L2Cache represents a two-level cache configuration.
 type L2Cache[T any] struct {
+	// ID of this instance on the invalidation bus.
+	id string
 	// Options for enabling/disabling caches
 	opts *L2Options
 	// Local in-memory cache.
 	local *ristretto.Cache
 	// Remote cache backend
 	remote Cache[T]
+	// Statistics of each level, nil unless metrics are enabled.
+	localStats  *statsCounter
+	remoteStats *statsCounter
+	// cancel stops the invalidation bus subscription.
+	cancel context.CancelFunc
 }
 
 // NewL2 instantiates a new L2 cache.
@@ -33,67 +42,305 @@ func NewL2[T any](remote Cache[T], options ...L2Option) *L2Cache[T] {
 
 	// This won't return an error because we're passing valid parameters
 	local, _ := ristretto.NewCache(cfg)
-	return &L2Cache[T]{
+	l2 := &L2Cache[T]{
+		id:     uuid.New().String(),
 		opts:   opts,
 		local:  local,
 		remote: remote,
+		cancel: func() {},
+	}
+	if opts.Metrics {
+		l2.localStats = &statsCounter{}
+		l2.remoteStats = &statsCounter{}
+	}
+
+	if opts.Bus != nil && !opts.Disable {
+		var ctx context.Context
+		ctx, l2.cancel = context.WithCancel(context.Background())
+		opts.Bus.Subscribe(ctx, l2.invalidate)
+	}
+
+	return l2
+}
+
+// invalidate evicts the local entries named by an invalidation message
+// published by another instance.
+func (c *L2Cache[T]) invalidate(msg *InvalidationMessage) {
+	if msg.Source == c.id {
+		return
+	}
+
+	if msg.Clear {
+		c.local.Clear()
+		return
+	}
+
+	for _, key := range msg.Keys {
+		c.local.Del(key)
 	}
 }
 
+// publish tells the other instances to evict the given keys from their local cache.
+func (c *L2Cache[T]) publish(ctx context.Context, msg *InvalidationMessage) error {
+	if c.opts.Bus == nil {
+		return nil
+	}
+
+	msg.Source = c.id
+	if err := c.opts.Bus.Publish(ctx, msg); err != nil {
+		return fmt.Errorf("unable to publish cache invalidation: %w", err)
+	}
+
+	return nil
+}
+
 // Get returns the obj stored in cache if it exists.
 func (c *L2Cache[T]) Get(ctx context.Context, key any) (T, error) {
 	value, _, err := c.GetWithTTL(ctx, key)
 	return value, err
 }
 
-// GetWithTTL returns the obj stored in cache and its corresponding TTL, also a bool that is true if the
-// item was found and is not expired.
+// GetWithTTL returns the obj stored in cache and its corresponding TTL. The
+// local cache is checked first, on a miss the object is read from the remote
+// cache and kept locally for at most the local TTL.
 func (c *L2Cache[T]) GetWithTTL(ctx context.Context, key any) (T, time.Duration, error) {
 	if !c.opts.Disable {
-		ttl, found := c.local.GetTTL(keyFunc(key))
-		if !found {
-			return *new(T), 0, store.ErrKeyNotFound
+		if value, ttl, found := c.getLocal(keyFunc(key)); found {
+			c.localStats.get(nil)
+			return value, ttl, nil
 		}
+		c.localStats.get(store.ErrKeyNotFound)
+	}
+
+	value, ttl, err := c.remote.GetWithTTL(ctx, key)
+	c.remoteStats.get(err)
+	if err != nil {
+		return value, ttl, err
+	}
 
-		value, _ := c.local.Get(keyFunc(key))
-		return value.(T), ttl, nil
+	if !c.opts.Disable {
+		_ = c.local.SetWithTTL(keyFunc(key), value, 0, c.opts.localTTL(ttl))
 	}
 
-	return c.remote.GetWithTTL(ctx, key)
+	return value, ttl, nil
+}
+
+// getLocal returns the obj stored in the local cache and its corresponding TTL.
+func (c *L2Cache[T]) getLocal(key string) (T, time.Duration, bool) {
+	ttl, found := c.local.GetTTL(key)
+	if !found {
+		return *new(T), 0, false
+	}
+
+	// The entry may expire between both lookups.
+	value, found := c.local.Get(key)
+	if !found {
+		return *new(T), 0, false
+	}
+
+	obj, ok := value.(T)
+	return obj, ttl, ok
 }
 
 // Set populates the cache item using the given key.
 func (c *L2Cache[T]) Set(ctx context.Context, key any, obj T) error {
 	if !c.opts.Disable {
-		_ = c.local.Set(keyFunc(key), obj, 0)
+		_ = c.local.SetWithTTL(keyFunc(key), obj, 0, c.opts.localTTL(0))
+		c.localStats.set(nil)
+	}
+
+	err := c.remote.Set(ctx, key, obj)
+	c.remoteStats.set(err)
+	if err != nil {
+		return err
 	}
 
-	return c.remote.Set(ctx, key, obj)
+	return c.publish(ctx, &InvalidationMessage{Keys: []string{keyFunc(key)}})
 }
 
 // SetWithTTL populates the cache item using the given key and TTL.
 func (c *L2Cache[T]) SetWithTTL(ctx context.Context, key any, obj T, ttl time.Duration) error {
 	if !c.opts.Disable {
-		_ = c.local.SetWithTTL(keyFunc(key), obj, 0, ttl)
+		_ = c.local.SetWithTTL(keyFunc(key), obj, 0, c.opts.localTTL(ttl))
+		c.localStats.set(nil)
 	}
 
-	return c.remote.SetWithTTL(ctx, key, obj, ttl)
+	err := c.remote.SetWithTTL(ctx, key, obj, ttl)
+	c.remoteStats.set(err)
+	if err != nil {
+		return err
+	}
+
+	return c.publish(ctx, &InvalidationMessage{Keys: []string{keyFunc(key)}})
 }
 
 // Del removes the cache item using the given key.
 func (c *L2Cache[T]) Del(ctx context.Context, key any) error {
 	if !c.opts.Disable {
 		c.local.Del(keyFunc(key))
+		c.localStats.del(nil)
+	}
+
+	err := c.remote.Del(ctx, key)
+	c.remoteStats.del(err)
+	if err != nil {
+		return err
 	}
-	return c.remote.Del(ctx, key)
+
+	return c.publish(ctx, &InvalidationMessage{Keys: []string{keyFunc(key)}})
 }
 
-// Clear resets all cache data.
-func (c *L2Cache[T]) Clear(ctx context.Context) error {
+// GetMany returns the objs stored in cache for all the given keys. The keys
+// missing from the local cache are read from the remote cache in one call.
+func (c *L2Cache[T]) GetMany(ctx context.Context, keys ...any) (map[any]T, error) {
+	objs := make(map[any]T, len(keys))
+	missing := keys
+	if !c.opts.Disable {
+		missing = make([]any, 0, len(keys))
+		for _, key := range keys {
+			if value, _, found := c.getLocal(keyFunc(key)); found {
+				objs[key] = value
+				continue
+			}
+			missing = append(missing, key)
+		}
+		c.localStats.getMany(len(keys), len(objs), nil)
+	}
+
+	if len(missing) == 0 {
+		return objs, nil
+	}
+
+	found, err := c.remote.GetMany(ctx, missing...)
+	c.remoteStats.getMany(len(missing), len(found), err)
+	if err != nil {
+		return objs, err
+	}
+
+	// Bulk reads do not return the remote TTLs, so the objects are only kept
+	// locally when a local TTL bounds how long they can go stale.
+	for key, value := range found {
+		objs[key] = value
+		if !c.opts.Disable && c.opts.LocalTTL > 0 {
+			_ = c.local.SetWithTTL(keyFunc(key), value, 0, c.opts.LocalTTL)
+		}
+	}
+
+	return objs, nil
+}
+
+// SetMany populates the cache items using the given keys and TTL.
+func (c *L2Cache[T]) SetMany(ctx context.Context, items map[any]T, ttl time.Duration) error {
+	keys := make([]string, 0, len(items))
+	for key, obj := range items {
+		keys = append(keys, keyFunc(key))
+		if !c.opts.Disable {
+			_ = c.local.SetWithTTL(keyFunc(key), obj, 0, c.opts.localTTL(ttl))
+			c.localStats.set(nil)
+		}
+	}
+
+	err := c.remote.SetMany(ctx, items, ttl)
+	c.remoteStats.set(err)
+	if err != nil {
+		return err
+	}
+
+	return c.publish(ctx, &InvalidationMessage{Keys: keys})
+}
+
+// DelMany removes the cache items using the given keys.
+func (c *L2Cache[T]) DelMany(ctx context.Context, keys ...any) error {
+	localKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		localKeys = append(localKeys, keyFunc(key))
+		if !c.opts.Disable {
+			c.local.Del(keyFunc(key))
+			c.localStats.del(nil)
+		}
+	}
+
+	err := c.remote.DelMany(ctx, keys...)
+	c.remoteStats.del(err)
+	if err != nil {
+		return err
+	}
+
+	return c.publish(ctx, &InvalidationMessage{Keys: localKeys})
+}
+
+// SetWithTags populates the cache item using the given key and TTL, and attaches the tags to it.
+func (c *L2Cache[T]) SetWithTags(ctx context.Context, key any, obj T, ttl time.Duration, tags ...string) error {
+	if !c.opts.Disable {
+		_ = c.local.SetWithTTL(keyFunc(key), obj, 0, c.opts.localTTL(ttl))
+		c.localStats.set(nil)
+	}
+
+	err := c.remote.SetWithTags(ctx, key, obj, ttl, tags...)
+	c.remoteStats.set(err)
+	if err != nil {
+		return err
+	}
+
+	return c.publish(ctx, &InvalidationMessage{Keys: []string{keyFunc(key)}})
+}
+
+// InvalidateTags removes the cache items attached to any of the given tags.
+//
+// The local cache does not know the tags of the items it read through from
+// the remote cache, so it is cleared on every instance.
+func (c *L2Cache[T]) InvalidateTags(ctx context.Context, tags ...string) error {
+	err := c.remote.InvalidateTags(ctx, tags...)
+	c.remoteStats.del(err)
+	if err != nil {
+		return err
+	}
+
+	return c.clearLocal(ctx)
+}
+
+// DelByPrefix removes the cache items whose key starts with the given prefix.
+//
+// The local cache cannot enumerate its keys, so it is cleared on every instance.
+func (c *L2Cache[T]) DelByPrefix(ctx context.Context, prefix string) error {
+	err := c.remote.DelByPrefix(ctx, prefix)
+	c.remoteStats.del(err)
+	if err != nil {
+		return err
+	}
+
+	return c.clearLocal(ctx)
+}
+
+// clearLocal clears the local cache of this and every other instance.
+func (c *L2Cache[T]) clearLocal(ctx context.Context) error {
 	if !c.opts.Disable {
 		c.local.Clear()
 	}
-	return c.remote.Clear(ctx)
+
+	return c.publish(ctx, &InvalidationMessage{Clear: true})
+}
+
+// Clear resets all cache data.
+func (c *L2Cache[T]) Clear(ctx context.Context) error {
+	if err := c.remote.Clear(ctx); err != nil {
+		return err
+	}
+
+	return c.clearLocal(ctx)
+}
+
+// LayerStats returns the statistics of the local and remote levels. It returns
+// nil unless metrics are enabled with L2WithMetrics.
+func (c *L2Cache[T]) LayerStats() []LayerStats {
+	if !c.opts.Metrics {
+		return nil
+	}
+
+	return []LayerStats{
+		{Layer: "local", Stats: c.localStats.snapshot()},
+		{Layer: "remote", Stats: c.remoteStats.snapshot()},
+	}
 }
 
 // // Wait waits for all cache operations to complete.
@@ -103,3 +350,13 @@ func (c *L2Cache[T]) Wait(ctx context.Context) {
 	}
 	c.remote.Wait(ctx)
 }
+
+// Close stops listening for invalidations from other instances and releases
+// the local cache. It does not close the invalidation bus, which is owned by
+// the caller.
+func (c *L2Cache[T]) Close() error {
+	c.cancel()
+	c.local.Close()
+
+	return nil
+}
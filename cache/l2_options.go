@@ -3,6 +3,8 @@
 package cache
 
 import (
+	"time"
+
 	"github.com/dgraph-io/ristretto"
 )
 
@@ -15,6 +17,12 @@ type L2Options struct {
 	// you need to restart the service.
 	Disable bool
 
+	// LocalTTL is the maximum time an entry is kept in the local cache. Local
+	// entries never outlive their remote counterpart, and without a bus this
+	// bounds how long other instances may serve a stale value. Zero keeps
+	// local entries as long as the remote ones.
+	LocalTTL time.Duration
+
 	// NumCounters determines the number of counters (keys) to keep that hold
 	// access frequency information. It's generally a good idea to have more
 	// counters than the max cache capacity, as this will improve eviction
@@ -44,8 +52,13 @@ type L2Options struct {
 	// Metrics determines whether cache statistics are kept during the cache's
 	// lifetime. There *is* some overhead to keeping statistics, so you should
 	// only set this flag to true when testing or throughput performance isn't a
-	// major factor.
+	// major factor. The local and remote statistics are available through
+	// L2Cache.LayerStats.
 	Metrics bool
+
+	// Bus broadcasts writes to the other instances of the cache, so that they
+	// evict their local copy of the written keys.
+	Bus InvalidationBus
 }
 
 // L2WithNumCounters sets the number of counters for L2 cache.
@@ -55,6 +68,13 @@ func L2WithNumCounters(numCounters int64) L2Option {
 	}
 }
 
+// L2WithLocalTTL sets the maximum time an entry is kept in the local cache.
+func L2WithLocalTTL(ttl time.Duration) L2Option {
+	return func(opts *L2Options) {
+		opts.LocalTTL = ttl
+	}
+}
+
 // L2WithDisableCache enables or disables the local cache for L2.
 func L2WithDisableCache(disable bool) L2Option {
 	return func(opts *L2Options) {
@@ -69,6 +89,13 @@ func L2WithMetrics(enable bool) L2Option {
 	}
 }
 
+// L2WithInvalidationBus sets the bus used to invalidate the local caches of other instances.
+func L2WithInvalidationBus(bus InvalidationBus) L2Option {
+	return func(opts *L2Options) {
+		opts.Bus = bus
+	}
+}
+
 // NewL2Options instantiates a new L2Options with default values.
 func NewL2Options() *L2Options {
 	return &L2Options{
@@ -79,6 +106,16 @@ func NewL2Options() *L2Options {
 	}
 }
 
+// localTTL returns the TTL of a local entry whose remote counterpart expires
+// after ttl, zero meaning it does not expire.
+func (o *L2Options) localTTL(ttl time.Duration) time.Duration {
+	if o.LocalTTL > 0 && (ttl <= 0 || o.LocalTTL < ttl) {
+		return o.LocalTTL
+	}
+
+	return ttl
+}
+
 // ApplyTo applies the L2Options to a ristretto.Config.
 func (o *L2Options) ApplyTo(cfg *ristretto.Config) {
 	cfg.NumCounters = o.NumCounters
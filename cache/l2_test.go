@@ -0,0 +1,99 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package cache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// localBus is an in-process stand-in for an invalidation bus.
+type localBus struct {
+	mu       sync.Mutex
+	handlers []func(msg *InvalidationMessage)
+}
+
+func (b *localBus) Publish(ctx context.Context, msg *InvalidationMessage) error {
+	b.mu.Lock()
+	handlers := append([]func(msg *InvalidationMessage){}, b.handlers...)
+	b.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(msg)
+	}
+	return nil
+}
+
+func (b *localBus) Subscribe(ctx context.Context, handler func(msg *InvalidationMessage)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, handler)
+}
+
+func TestL2Invalidation(t *testing.T) {
+	bus := &localBus{}
+	remote := newTestCache[string]()
+	a := NewL2[string](remote, L2WithInvalidationBus(bus))
+	b := NewL2[string](remote, L2WithInvalidationBus(bus))
+	defer a.Close()
+	defer b.Close()
+
+	ctx := context.Background()
+	if err := b.Set(ctx, "key", "stale"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	b.Wait(ctx)
+
+	if err := a.Set(ctx, "key", "fresh"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	a.Wait(ctx)
+
+	if value, found := b.local.Get("key"); found {
+		t.Errorf("replica kept stale local value %v", value)
+	}
+	if value, found := a.local.Get("key"); !found || value != "fresh" {
+		t.Errorf("writer local value = %v, %v, want fresh", value, found)
+	}
+
+	if err := a.Clear(ctx); err != nil {
+		t.Fatalf("Clear() error = %v", err)
+	}
+	if _, found := a.local.Get("key"); found {
+		t.Errorf("Clear() kept the local value")
+	}
+}
+
+func TestL2ReadThrough(t *testing.T) {
+	remote := newTestCache[string]()
+	c := NewL2[string](remote, L2WithLocalTTL(time.Minute), L2WithMetrics(true))
+	defer c.Close()
+
+	ctx := context.Background()
+	if err := remote.SetWithTTL(ctx, "key", "value", time.Hour); err != nil {
+		t.Fatalf("SetWithTTL() error = %v", err)
+	}
+
+	if value, err := c.Get(ctx, "key"); err != nil || value != "value" {
+		t.Fatalf("Get() = %q, %v, want value from remote", value, err)
+	}
+	c.Wait(ctx)
+
+	value, ttl, err := c.GetWithTTL(ctx, "key")
+	if err != nil || value != "value" {
+		t.Fatalf("GetWithTTL() = %q, %v, want value from local", value, err)
+	}
+	if ttl <= 0 || ttl > time.Minute {
+		t.Errorf("local TTL = %v, want capped at %v", ttl, time.Minute)
+	}
+
+	stats := c.LayerStats()
+	if local := stats[0]; local.Hits != 1 || local.Misses != 1 {
+		t.Errorf("local stats = %+v, want 1 hit and 1 miss", local.Stats)
+	}
+	if remote := stats[1]; remote.Hits != 1 {
+		t.Errorf("remote stats = %+v, want 1 hit", remote.Stats)
+	}
+}
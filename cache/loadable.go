@@ -4,34 +4,90 @@ package cache
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sync"
 	"time"
+
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/snail-plus/gopkg/cache/store"
+	"github.com/snail-plus/gopkg/cache/store/memory"
+)
+
+var (
+	// ErrLoadTimeout is returned when waiting for a load exceeds LoadableOptions.LoadTimeout.
+	ErrLoadTimeout = errors.New("timed out waiting for load")
+	// ErrCachedNotFound is returned for a key whose load recently failed with
+	// a not found error, see LoadableWithNegativeCache. It matches
+	// store.ErrKeyNotFound.
+	ErrCachedNotFound = fmt.Errorf("%w: cached not found result", store.ErrKeyNotFound)
 )
 
 // loadableKeyValue represents a key-value pair to be loaded into the cache.
 type loadableKeyValue[T any] struct {
 	key   any
 	value T
+	ttl   time.Duration
 }
 
 // LoadFunction is a function type for loading data into the cache.
 type LoadFunction[T any] func(ctx context.Context, key any) (T, error)
 
+// LoadManyFunction is a function type for loading the data of several keys
+// into the cache at once. Keys that do not exist are left out of the returned map.
+type LoadManyFunction[T any] func(ctx context.Context, keys []any) (map[any]T, error)
+
 // LoadableCache represents a cache that uses a function to load data.
 type LoadableCache[T any] struct {
-	loadFunc   LoadFunction[T]
-	cache      Cache[T]
+	opts         *LoadableOptions
+	loadFunc     LoadFunction[T]
+	loadManyFunc LoadManyFunction[T]
+	cache        Cache[T]
+	// negative holds the keys whose load failed with a not found error, nil
+	// unless negative caching is enabled.
+	negative   *memory.MemoryStore
+	group      singleflight.Group
 	setChannel chan *loadableKeyValue[T]
 	wg         *sync.WaitGroup
+	// refreshing holds the keys with a background reload in flight.
+	refreshing sync.Map
+	refreshWg  sync.WaitGroup
+	stats      statsCounter
 }
 
 // NewLoadable instanciates a new cache that uses a function to load data.
-func NewLoadable[T any](loadFunc LoadFunction[T], cache Cache[T]) *LoadableCache[T] {
+func NewLoadable[T any](loadFunc LoadFunction[T], cache Cache[T], options ...LoadableOption) *LoadableCache[T] {
+	return NewLoadableMany(loadFunc, nil, cache, options...)
+}
+
+// NewLoadableMany instanciates a new cache that uses a function to load data,
+// and a batch function to load the keys missed by GetMany. Without a batch
+// function, the keys missed by GetMany are loaded one by one, concurrently.
+func NewLoadableMany[T any](
+	loadFunc LoadFunction[T],
+	loadManyFunc LoadManyFunction[T],
+	cache Cache[T],
+	options ...LoadableOption,
+) *LoadableCache[T] {
+	opts := NewLoadableOptions()
+	for _, opt := range options {
+		opt(opts)
+	}
+	opts.Complete()
+
 	loadable := &LoadableCache[T]{
-		loadFunc:   loadFunc,
-		cache:      cache,
-		setChannel: make(chan *loadableKeyValue[T], 10000),
-		wg:         &sync.WaitGroup{},
+		opts:         opts,
+		loadFunc:     loadFunc,
+		loadManyFunc: loadManyFunc,
+		cache:        cache,
+		setChannel:   make(chan *loadableKeyValue[T], 10000),
+		wg:           &sync.WaitGroup{},
+	}
+
+	if opts.negative() {
+		loadable.negative = memory.NewMemory(memory.WithMaxEntries(opts.NegativeMaxEntries))
 	}
 
 	loadable.wg.Add(1)
@@ -45,81 +101,425 @@ func (c *LoadableCache[T]) Sync() {
 	defer c.wg.Done()
 
 	for item := range c.setChannel {
+		if item.ttl > 0 {
+			c.SetWithTTL(context.Background(), item.key, item.value, item.ttl)
+			continue
+		}
 		c.Set(context.Background(), item.key, item.value)
 	}
 }
 
 // Get returns the obj stored in cache if it exists.
 func (c *LoadableCache[T]) Get(ctx context.Context, key any) (T, error) {
+	obj, _, err := c.GetShared(ctx, key)
+	return obj, err
+}
+
+// GetShared returns the obj stored in cache if it exists, otherwise it loads it
+// using the load function. The returned bool reports whether the object was
+// produced by a load that was shared with other concurrent callers.
+func (c *LoadableCache[T]) GetShared(ctx context.Context, key any) (T, bool, error) {
+	var obj T
 	var err error
 
-	obj, err := c.cache.Get(ctx, key)
+	if c.opts.SoftTTL > 0 {
+		obj, _, err = c.lookup(ctx, key)
+	} else {
+		obj, err = c.cache.Get(ctx, key)
+	}
+	c.stats.get(err)
 	if err == nil {
-		return obj, nil
+		return obj, false, nil
 	}
-
-	// Unable to find in cache, try to load it from load function
-	obj, err = c.loadFunc(ctx, key)
-	if err != nil {
-		return obj, err
+	if c.notFound(ctx, key) {
+		return obj, false, ErrCachedNotFound
 	}
 
-	// Then, put it back in cache
-	c.setChannel <- &loadableKeyValue[T]{key, obj}
-
-	return obj, err
+	// Unable to find in cache, try to load it from load function
+	return c.load(ctx, key)
 }
 
 // GetWithTTL retrieves the object from the cache with its time to live (TTL) or loads it using the load function if not found.
 func (c *LoadableCache[T]) GetWithTTL(ctx context.Context, key any) (T, time.Duration, error) {
 	var err error
 
-	obj, ttl, err := c.cache.GetWithTTL(ctx, key)
+	obj, ttl, err := c.lookup(ctx, key)
+	c.stats.get(err)
 	if err == nil {
 		return obj, ttl, nil
 	}
+	if c.notFound(ctx, key) {
+		return obj, 0, ErrCachedNotFound
+	}
 
 	// Unable to find in cache, try to load it from load function
-	obj, err = c.loadFunc(ctx, key)
+	obj, _, err = c.load(ctx, key)
 	if err != nil {
 		return obj, 0, err
 	}
 
-	// Then, put it back in cache
-	c.setChannel <- &loadableKeyValue[T]{key, obj}
+	return obj, c.opts.HardTTL, nil
+}
+
+// lookup reads the object from the cache. When the object has passed the soft
+// TTL it is still returned, and a reload is started in the background.
+func (c *LoadableCache[T]) lookup(ctx context.Context, key any) (T, time.Duration, error) {
+	obj, ttl, err := c.cache.GetWithTTL(ctx, key)
+	if err == nil && c.opts.stale(ttl) {
+		c.refresh(ctx, key)
+	}
 
 	return obj, ttl, err
 }
 
+// refresh reloads the object in the background, unless a reload for the
+// same key is already in flight.
+func (c *LoadableCache[T]) refresh(ctx context.Context, key any) {
+	k := keyFunc(key)
+	if _, loaded := c.refreshing.LoadOrStore(k, struct{}{}); loaded {
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	c.refreshWg.Add(1)
+	go func() {
+		defer c.refreshWg.Done()
+		defer c.refreshing.Delete(k)
+
+		// Write back synchronously so the key is fresh again before other
+		// readers are allowed to start a new refresh. On failure the stale
+		// object is kept until it reaches the hard TTL.
+		_, _, _ = c.group.Do(k, func() (any, error) {
+			obj, err := c.timedLoad(ctx, key)
+			if c.isNotFound(err) {
+				// The key is gone, stop serving the stale object.
+				c.setNotFound(ctx, key)
+				return obj, c.cache.Del(ctx, key)
+			}
+			if err != nil {
+				return obj, err
+			}
+
+			return obj, c.cache.SetWithTTL(ctx, key, obj, c.opts.HardTTL)
+		})
+	}()
+}
+
+// load calls the load function for the given key. Only one load per key is in
+// flight at a time, concurrent callers wait for it and share its result.
+func (c *LoadableCache[T]) load(ctx context.Context, key any) (T, bool, error) {
+	ch := c.group.DoChan(keyFunc(key), func() (any, error) {
+		// The load is shared by all waiting callers, so it must not be
+		// cancelled when the caller that started it gives up.
+		obj, err := c.timedLoad(context.WithoutCancel(ctx), key)
+		if c.isNotFound(err) {
+			c.setNotFound(ctx, key)
+		}
+		if err != nil {
+			return obj, err
+		}
+
+		// Then, put it back in cache
+		c.setChannel <- &loadableKeyValue[T]{key, obj, c.opts.HardTTL}
+
+		return obj, nil
+	})
+
+	var timeout <-chan time.Time
+	if c.opts.LoadTimeout > 0 {
+		timer := time.NewTimer(c.opts.LoadTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case res := <-ch:
+		obj, _ := res.Val.(T)
+		return obj, res.Shared, res.Err
+	case <-timeout:
+		return *new(T), false, ErrLoadTimeout
+	case <-ctx.Done():
+		return *new(T), false, ctx.Err()
+	}
+}
+
+// GetMany returns the objs stored in cache for all the given keys, and loads
+// the missing ones. Only the missed keys are passed to the batch load function.
+// Bulk reads do not return TTLs, so they never trigger refresh-ahead.
+func (c *LoadableCache[T]) GetMany(ctx context.Context, keys ...any) (map[any]T, error) {
+	objs, err := c.cache.GetMany(ctx, keys...)
+	c.stats.getMany(len(keys), len(objs), err)
+	if err != nil {
+		// Unable to read from cache, try to load every key.
+		objs = make(map[any]T, len(keys))
+	}
+
+	missing := make([]any, 0, len(keys)-len(objs))
+	for _, key := range keys {
+		if _, ok := objs[key]; !ok && !c.notFound(ctx, key) {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 {
+		return objs, nil
+	}
+
+	loaded, err := c.loadMany(ctx, missing)
+	for key, obj := range loaded {
+		objs[key] = obj
+	}
+
+	return objs, err
+}
+
+// loadMany loads the given keys with the batch load function and writes them
+// back to the cache, or falls back to loading them one by one.
+func (c *LoadableCache[T]) loadMany(ctx context.Context, keys []any) (map[any]T, error) {
+	if c.loadManyFunc == nil {
+		return c.loadEach(ctx, keys)
+	}
+
+	if c.opts.LoadTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.opts.LoadTimeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	objs, err := c.loadManyFunc(ctx, keys)
+	c.stats.load(time.Since(start), err)
+	if err != nil {
+		return nil, err
+	}
+
+	// The keys left out by the batch load function do not exist.
+	for _, key := range keys {
+		if _, ok := objs[key]; !ok {
+			c.setNotFound(ctx, key)
+		}
+	}
+
+	if len(objs) > 0 {
+		// A failed write back only costs another load later.
+		_ = c.cache.SetMany(context.WithoutCancel(ctx), objs, c.opts.HardTTL)
+	}
+
+	return objs, nil
+}
+
+// loadEach loads the given keys concurrently with the load function.
+func (c *LoadableCache[T]) loadEach(ctx context.Context, keys []any) (map[any]T, error) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var errs []error
+	objs := make(map[any]T, len(keys))
+
+	for _, key := range keys {
+		wg.Add(1)
+		go func(key any) {
+			defer wg.Done()
+
+			obj, _, err := c.load(ctx, key)
+			mu.Lock()
+			defer mu.Unlock()
+			if c.isNotFound(err) {
+				return
+			}
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			objs[key] = obj
+		}(key)
+	}
+	wg.Wait()
+
+	return objs, errors.Join(errs...)
+}
+
+// Warm preloads the given keys with the load function, at most concurrency at
+// a time, so that a freshly started service does not hit its source of truth
+// with a burst of misses. The keys already cached, such as those restored from
+// a snapshot, are skipped, and the keys being loaded by concurrent reads share
+// their load. The loaded objects are written to the cache before Warm returns.
+// A failed key does not stop the others, the errors are joined.
+func (c *LoadableCache[T]) Warm(ctx context.Context, keys []any, concurrency int) error {
+	cached, err := c.cache.GetMany(ctx, keys...)
+	if err != nil {
+		cached = nil
+	}
+
+	var mu sync.Mutex
+	var errs []error
+	var g errgroup.Group
+	if concurrency > 0 {
+		g.SetLimit(concurrency)
+	}
+	for _, key := range keys {
+		if _, ok := cached[key]; ok {
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		g.Go(func() error {
+			// Share the load with the concurrent readers of the key, like load.
+			obj, err, shared := c.group.Do(keyFunc(key), func() (any, error) {
+				obj, err := c.timedLoad(context.WithoutCancel(ctx), key)
+				if c.isNotFound(err) {
+					c.setNotFound(ctx, key)
+				}
+				if err != nil {
+					return obj, err
+				}
+
+				return obj, c.cache.SetWithTTL(ctx, key, obj, c.opts.HardTTL)
+			})
+			if c.isNotFound(err) {
+				return nil
+			}
+			if err == nil && shared {
+				// A reader's load writes back in the background.
+				v, _ := obj.(T)
+				err = c.cache.SetWithTTL(ctx, key, v, c.opts.HardTTL)
+			}
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("failed to warm key %v: %w", key, err))
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// isNotFound reports whether a load error is a not found result to cache.
+func (c *LoadableCache[T]) isNotFound(err error) bool {
+	return err != nil && c.negative != nil && c.opts.IsNotFound(err)
+}
+
+// notFound reports whether a recent load of the key failed with a not found error.
+func (c *LoadableCache[T]) notFound(ctx context.Context, key any) bool {
+	if c.negative == nil {
+		return false
+	}
+
+	_, err := c.negative.Get(ctx, keyFunc(key))
+	return err == nil
+}
+
+// setNotFound caches a not found result for the key.
+func (c *LoadableCache[T]) setNotFound(ctx context.Context, key any) {
+	if c.negative != nil {
+		_ = c.negative.SetWithTTL(ctx, keyFunc(key), struct{}{}, c.opts.NegativeTTL)
+	}
+}
+
+// forget drops the cached not found results of the given keys.
+func (c *LoadableCache[T]) forget(ctx context.Context, keys ...any) {
+	if c.negative == nil {
+		return
+	}
+
+	for _, key := range keys {
+		_ = c.negative.Del(ctx, keyFunc(key))
+	}
+}
+
+// timedLoad calls the load function and records its statistics.
+func (c *LoadableCache[T]) timedLoad(ctx context.Context, key any) (T, error) {
+	start := time.Now()
+	obj, err := c.loadFunc(ctx, key)
+	c.stats.load(time.Since(start), err)
+
+	return obj, err
+}
+
+// Stats returns a snapshot of the cache lookups and loads statistics.
+func (c *LoadableCache[T]) Stats() Stats {
+	return c.stats.snapshot()
+}
+
 // Set sets a value in available caches.
 func (c *LoadableCache[T]) Set(ctx context.Context, key any, obj T) error {
+	c.forget(ctx, key)
 	return c.cache.Set(ctx, key, obj)
 }
 
 // SetWithTTL sets a value in the cache with a specified time to live (TTL).
 func (c *LoadableCache[T]) SetWithTTL(ctx context.Context, key any, obj T, ttl time.Duration) error {
+	c.forget(ctx, key)
 	return c.cache.SetWithTTL(ctx, key, obj, ttl)
 }
 
-// Del removes a value from cache.
+// Del removes a value from cache, along with its cached not found result.
 func (c *LoadableCache[T]) Del(ctx context.Context, key any) error {
+	c.forget(ctx, key)
 	return c.cache.Del(ctx, key)
 }
 
-// Clear resets all cache data.
+// SetMany sets values in the cache with a specified time to live (TTL).
+func (c *LoadableCache[T]) SetMany(ctx context.Context, items map[any]T, ttl time.Duration) error {
+	for key := range items {
+		c.forget(ctx, key)
+	}
+	return c.cache.SetMany(ctx, items, ttl)
+}
+
+// DelMany removes values from cache, along with their cached not found results.
+func (c *LoadableCache[T]) DelMany(ctx context.Context, keys ...any) error {
+	c.forget(ctx, keys...)
+	return c.cache.DelMany(ctx, keys...)
+}
+
+// SetWithTags sets a value in the cache with a specified TTL and attaches the tags to it.
+func (c *LoadableCache[T]) SetWithTags(ctx context.Context, key any, obj T, ttl time.Duration, tags ...string) error {
+	c.forget(ctx, key)
+	return c.cache.SetWithTags(ctx, key, obj, ttl, tags...)
+}
+
+// InvalidateTags removes the values attached to any of the given tags.
+func (c *LoadableCache[T]) InvalidateTags(ctx context.Context, tags ...string) error {
+	return c.cache.InvalidateTags(ctx, tags...)
+}
+
+// DelByPrefix removes the values whose key starts with the given prefix.
+func (c *LoadableCache[T]) DelByPrefix(ctx context.Context, prefix string) error {
+	if c.negative != nil {
+		_ = c.negative.DelByPrefix(ctx, prefix)
+	}
+	return c.cache.DelByPrefix(ctx, prefix)
+}
+
+// Clear resets all cache data, including the cached not found results.
 func (c *LoadableCache[T]) Clear(ctx context.Context) error {
+	if c.negative != nil {
+		_ = c.negative.Clear(ctx)
+	}
 	return c.cache.Clear(ctx)
 }
 
 // Wait waits for all operations to finish.
 func (c *LoadableCache[T]) Wait(ctx context.Context) {
+	c.refreshWg.Wait()
 	c.cache.Wait(ctx)
 }
 
 // Close closes the setChannel and waits for all operations to finish.
 func (c *LoadableCache[T]) Close() error {
+	c.refreshWg.Wait()
 	close(c.setChannel)
 	c.wg.Wait()
+	if c.negative != nil {
+		_ = c.negative.Close()
+	}
 
 	return nil
 }
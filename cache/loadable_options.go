@@ -0,0 +1,102 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package cache
+
+import (
+	"time"
+)
+
+// LoadableOption represents a loadable cache option function.
+type LoadableOption func(o *LoadableOptions)
+
+// LoadableOptions represents the options for loadable cache configuration.
+type LoadableOptions struct {
+	// LoadTimeout bounds how long a caller waits for the load of a missing key.
+	// Only one load per key is in flight at a time, concurrent callers wait for
+	// its result. A zero value means wait until the load finishes or the
+	// caller's context is done.
+	LoadTimeout time.Duration
+
+	// SoftTTL enables refresh-ahead. Once a cached entry is older than SoftTTL,
+	// reads still return it immediately but trigger an asynchronous reload.
+	SoftTTL time.Duration
+	// HardTTL is the TTL loaded entries are written back with. Once it is
+	// reached the entry is gone and reads block on the reload. When SoftTTL
+	// is set and HardTTL is not, HardTTL defaults to twice SoftTTL.
+	HardTTL time.Duration
+
+	// IsNotFound enables negative caching. It reports whether an error of the
+	// load function means the key does not exist. Such results are cached for
+	// NegativeTTL, and reads of the key fail with ErrCachedNotFound without
+	// calling the load function until then.
+	IsNotFound func(err error) bool
+	// NegativeTTL is how long a not found result is cached.
+	NegativeTTL time.Duration
+	// NegativeMaxEntries bounds the number of not found results kept in
+	// memory, the least recently used ones are dropped first.
+	NegativeMaxEntries int
+}
+
+// LoadableWithLoadTimeout sets the maximum time a caller waits for a load to finish.
+func LoadableWithLoadTimeout(timeout time.Duration) LoadableOption {
+	return func(opts *LoadableOptions) {
+		opts.LoadTimeout = timeout
+	}
+}
+
+// LoadableWithSoftTTL sets the age after which entries are reloaded in the background.
+func LoadableWithSoftTTL(ttl time.Duration) LoadableOption {
+	return func(opts *LoadableOptions) {
+		opts.SoftTTL = ttl
+	}
+}
+
+// LoadableWithHardTTL sets the TTL loaded entries are written back with.
+func LoadableWithHardTTL(ttl time.Duration) LoadableOption {
+	return func(opts *LoadableOptions) {
+		opts.HardTTL = ttl
+	}
+}
+
+// LoadableWithNegativeCache caches the load errors matched by isNotFound for the given TTL.
+func LoadableWithNegativeCache(isNotFound func(err error) bool, ttl time.Duration) LoadableOption {
+	return func(opts *LoadableOptions) {
+		opts.IsNotFound = isNotFound
+		opts.NegativeTTL = ttl
+	}
+}
+
+// LoadableWithNegativeMaxEntries sets the maximum number of cached not found results.
+func LoadableWithNegativeMaxEntries(maxEntries int) LoadableOption {
+	return func(opts *LoadableOptions) {
+		opts.NegativeMaxEntries = maxEntries
+	}
+}
+
+// NewLoadableOptions instantiates a new LoadableOptions with default values.
+func NewLoadableOptions() *LoadableOptions {
+	return &LoadableOptions{
+		LoadTimeout: 0,
+		SoftTTL:     0,
+		HardTTL:     0,
+
+		NegativeMaxEntries: 10000,
+	}
+}
+
+// Complete fills in the options that depend on other options.
+func (o *LoadableOptions) Complete() {
+	if o.SoftTTL > 0 && o.HardTTL <= 0 {
+		o.HardTTL = 2 * o.SoftTTL
+	}
+}
+
+// negative reports whether not found results are cached.
+func (o *LoadableOptions) negative() bool {
+	return o.IsNotFound != nil && o.NegativeTTL > 0
+}
+
+// stale reports whether an entry with the given remaining TTL has passed the soft TTL.
+func (o *LoadableOptions) stale(ttl time.Duration) bool {
+	return o.SoftTTL > 0 && ttl > 0 && ttl <= o.HardTTL-o.SoftTTL
+}
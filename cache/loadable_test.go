@@ -0,0 +1,360 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	gocacheclient "github.com/patrickmn/go-cache"
+
+	"github.com/snail-plus/gopkg/cache/store/gocache"
+)
+
+type fakeGoCacheItem struct {
+	value      any
+	expiration time.Time
+}
+
+// fakeGoCache is an in-memory stand-in for a github.com/patrickmn/go-cache client.
+type fakeGoCache struct {
+	mu        sync.Mutex
+	items     map[string]fakeGoCacheItem
+	onEvicted func(string, any)
+}
+
+func newFakeGoCache() *fakeGoCache {
+	return &fakeGoCache{items: make(map[string]fakeGoCacheItem)}
+}
+
+func (f *fakeGoCache) Get(k string) (any, bool) {
+	value, _, found := f.GetWithExpiration(k)
+	return value, found
+}
+
+func (f *fakeGoCache) GetWithExpiration(k string) (any, time.Time, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	item, found := f.items[k]
+	if !found || (!item.expiration.IsZero() && time.Now().After(item.expiration)) {
+		return nil, time.Time{}, false
+	}
+	return item.value, item.expiration, true
+}
+
+func (f *fakeGoCache) Set(k string, x any, d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	item := fakeGoCacheItem{value: x}
+	if d > 0 {
+		item.expiration = time.Now().Add(d)
+	}
+	f.items[k] = item
+}
+
+func (f *fakeGoCache) Delete(k string) {
+	f.mu.Lock()
+	item, found := f.items[k]
+	delete(f.items, k)
+	onEvicted := f.onEvicted
+	f.mu.Unlock()
+
+	if found && onEvicted != nil {
+		onEvicted(k, item.value)
+	}
+}
+
+func (f *fakeGoCache) Flush() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.items = make(map[string]fakeGoCacheItem)
+}
+
+func (f *fakeGoCache) Items() map[string]gocacheclient.Item {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	items := make(map[string]gocacheclient.Item, len(f.items))
+	for k, item := range f.items {
+		if !item.expiration.IsZero() && time.Now().After(item.expiration) {
+			continue
+		}
+		var expiration int64
+		if !item.expiration.IsZero() {
+			expiration = item.expiration.UnixNano()
+		}
+		items[k] = gocacheclient.Item{Object: item.value, Expiration: expiration}
+	}
+	return items
+}
+
+func (f *fakeGoCache) OnEvicted(fn func(string, any)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.onEvicted = fn
+}
+
+func newTestCache[T any]() *DelegateCache[T] {
+	return New[T](gocache.NewGoCache(newFakeGoCache()))
+}
+
+func TestLoadableSingleFlight(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	loadFunc := func(ctx context.Context, key any) (string, error) {
+		calls.Add(1)
+		<-release
+		return "value", nil
+	}
+
+	loadable := NewLoadable[string](loadFunc, newTestCache[string]())
+	defer loadable.Close()
+
+	const callers = 50
+	var wg sync.WaitGroup
+	var shared atomic.Int32
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			obj, isShared, err := loadable.GetShared(context.Background(), "key")
+			if err != nil || obj != "value" {
+				t.Errorf("GetShared() = %q, %v", obj, err)
+			}
+			if isShared {
+				shared.Add(1)
+			}
+		}()
+	}
+
+	// Give the callers time to pile up behind the in-flight load.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("load function called %d times, want 1", got)
+	}
+	if shared.Load() == 0 {
+		t.Errorf("expected callers to report a shared result")
+	}
+}
+
+func TestLoadableLoadTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	loadFunc := func(ctx context.Context, key any) (string, error) {
+		<-release
+		return "value", nil
+	}
+
+	loadable := NewLoadable[string](loadFunc, newTestCache[string](), LoadableWithLoadTimeout(10*time.Millisecond))
+
+	_, err := loadable.Get(context.Background(), "key")
+	if !errors.Is(err, ErrLoadTimeout) {
+		t.Fatalf("Get() error = %v, want %v", err, ErrLoadTimeout)
+	}
+}
+
+func TestLoadableRefreshAhead(t *testing.T) {
+	var calls atomic.Int32
+	loadFunc := func(ctx context.Context, key any) (int32, error) {
+		return calls.Add(1), nil
+	}
+
+	cache := newTestCache[int32]()
+	loadable := NewLoadable[int32](loadFunc, cache, LoadableWithSoftTTL(50*time.Millisecond), LoadableWithHardTTL(time.Second))
+	defer loadable.Close()
+
+	ctx := context.Background()
+	if obj, err := loadable.Get(ctx, "key"); err != nil || obj != 1 {
+		t.Fatalf("Get() = %d, %v, want 1", obj, err)
+	}
+
+	// Wait for the asynchronous write-back of the first load.
+	for i := 0; i < 100; i++ {
+		if _, err := cache.Get(ctx, "key"); err == nil {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	// The stale object is returned right away and a reload starts in the background.
+	if obj, err := loadable.Get(ctx, "key"); err != nil || obj != 1 {
+		t.Fatalf("Get() = %d, %v, want stale 1", obj, err)
+	}
+
+	loadable.Wait(ctx)
+
+	if obj, err := loadable.Get(ctx, "key"); err != nil || obj != 2 {
+		t.Fatalf("Get() = %d, %v, want refreshed 2", obj, err)
+	}
+}
+
+func TestLoadableGetMany(t *testing.T) {
+	var missed []any
+	loadManyFunc := func(ctx context.Context, keys []any) (map[any]string, error) {
+		missed = keys
+		objs := make(map[any]string, len(keys))
+		for _, key := range keys {
+			// "c" does not exist.
+			if key != "c" {
+				objs[key] = "loaded-" + key.(string)
+			}
+		}
+		return objs, nil
+	}
+
+	cache := newTestCache[string]()
+	loadable := NewLoadableMany[string](nil, loadManyFunc, cache)
+	defer loadable.Close()
+
+	ctx := context.Background()
+	if err := cache.Set(ctx, "a", "cached-a"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	objs, err := loadable.GetMany(ctx, "a", "b", "c")
+	if err != nil {
+		t.Fatalf("GetMany() error = %v", err)
+	}
+	if len(missed) != 2 || missed[0] != "b" || missed[1] != "c" {
+		t.Errorf("load function called with %v, want [b c]", missed)
+	}
+	if len(objs) != 2 || objs["a"] != "cached-a" || objs["b"] != "loaded-b" {
+		t.Errorf("GetMany() = %v, want a and b", objs)
+	}
+
+	// Loaded objects are written back.
+	if obj, err := cache.Get(ctx, "b"); err != nil || obj != "loaded-b" {
+		t.Errorf("Get() = %q, %v, want loaded-b", obj, err)
+	}
+}
+
+func TestLoadableNegativeCache(t *testing.T) {
+	errNotFound := errors.New("record not found")
+	var calls atomic.Int32
+	loadFunc := func(ctx context.Context, key any) (string, error) {
+		calls.Add(1)
+		return "", errNotFound
+	}
+
+	isNotFound := func(err error) bool { return errors.Is(err, errNotFound) }
+	loadable := NewLoadable[string](loadFunc, newTestCache[string](), LoadableWithNegativeCache(isNotFound, time.Minute))
+	defer loadable.Close()
+
+	ctx := context.Background()
+	if _, err := loadable.Get(ctx, "missing"); !errors.Is(err, errNotFound) {
+		t.Fatalf("Get() error = %v, want %v", err, errNotFound)
+	}
+	if _, err := loadable.Get(ctx, "missing"); !errors.Is(err, ErrCachedNotFound) {
+		t.Fatalf("Get() error = %v, want %v", err, ErrCachedNotFound)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("load function called %d times, want 1", got)
+	}
+
+	// Writing the key drops the cached not found result.
+	if err := loadable.Set(ctx, "missing", "found"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if obj, err := loadable.Get(ctx, "missing"); err != nil || obj != "found" {
+		t.Errorf("Get() = %q, %v, want found", obj, err)
+	}
+}
+
+func TestLoadableWarm(t *testing.T) {
+	var inFlight, maxInFlight, calls atomic.Int32
+	loadFunc := func(ctx context.Context, key any) (string, error) {
+		calls.Add(1)
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		if key == "broken" {
+			return "", errors.New("load failed")
+		}
+		return "value of " + key.(string), nil
+	}
+
+	c := newTestCache[string]()
+	loadable := NewLoadable[string](loadFunc, c)
+	defer loadable.Close()
+
+	ctx := context.Background()
+	if err := c.Set(ctx, "cached", "already there"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	keys := []any{"a", "b", "c", "d", "e", "f", "cached", "broken"}
+	if err := loadable.Warm(ctx, keys, 2); err == nil {
+		t.Error("Warm() error = nil, want the error of the broken key")
+	}
+	if got := calls.Load(); got != 7 {
+		t.Errorf("load function called %d times, want 7", got)
+	}
+	if got := maxInFlight.Load(); got > 2 {
+		t.Errorf("%d concurrent loads, want at most 2", got)
+	}
+
+	// The warmed keys are cached when Warm returns.
+	if obj, err := c.Get(ctx, "f"); err != nil || obj != "value of f" {
+		t.Errorf("Get() = %q, %v, want value of f", obj, err)
+	}
+}
+
+func TestLoadableWarmSingleFlight(t *testing.T) {
+	var calls atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	loadFunc := func(ctx context.Context, key any) (string, error) {
+		if calls.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		return "value", nil
+	}
+
+	c := newTestCache[string]()
+	loadable := NewLoadable[string](loadFunc, c)
+	defer loadable.Close()
+
+	ctx := context.Background()
+	got := make(chan string)
+	go func() {
+		obj, _ := loadable.Get(ctx, "key")
+		got <- obj
+	}()
+	<-started
+
+	warmed := make(chan error)
+	go func() { warmed <- loadable.Warm(ctx, []any{"key"}, 1) }()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	if err := <-warmed; err != nil {
+		t.Errorf("Warm() error = %v", err)
+	}
+	if obj := <-got; obj != "value" {
+		t.Errorf("Get() = %q, want value", obj)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("load function called %d times, want 1", n)
+	}
+	if obj, err := c.Get(ctx, "key"); err != nil || obj != "value" {
+		t.Errorf("cached object = %q, %v, want value", obj, err)
+	}
+}
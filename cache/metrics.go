@@ -0,0 +1,141 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// MetricsCache is a cache wrapper that records statistics about the operations
+// performed on the wrapped cache.
+type MetricsCache[T any] struct {
+	name  string
+	cache Cache[T]
+	stats *statsCounter
+}
+
+// NewMetrics instantiates a new cache that records statistics under the given name.
+func NewMetrics[T any](name string, cache Cache[T]) *MetricsCache[T] {
+	return &MetricsCache[T]{
+		name:  name,
+		cache: cache,
+		stats: &statsCounter{},
+	}
+}
+
+// Name returns the name the statistics are recorded under.
+func (c *MetricsCache[T]) Name() string {
+	return c.name
+}
+
+// Stats returns a snapshot of the cache statistics. Load statistics are taken
+// from the wrapped cache when it keeps them, e.g. a LoadableCache.
+func (c *MetricsCache[T]) Stats() Stats {
+	stats := c.stats.snapshot()
+	if provider, ok := c.cache.(StatsProvider); ok {
+		inner := provider.Stats()
+		stats.Loads = inner.Loads
+		stats.LoadErrors = inner.LoadErrors
+		stats.LoadTime = inner.LoadTime
+	}
+
+	return stats
+}
+
+// LayerStats returns the per-layer statistics of the wrapped cache, if it keeps them.
+func (c *MetricsCache[T]) LayerStats() []LayerStats {
+	if provider, ok := c.cache.(LayeredStatsProvider); ok {
+		return provider.LayerStats()
+	}
+
+	return nil
+}
+
+// Get returns the obj stored in cache if it exists.
+func (c *MetricsCache[T]) Get(ctx context.Context, key any) (T, error) {
+	obj, err := c.cache.Get(ctx, key)
+	c.stats.get(err)
+	return obj, err
+}
+
+// GetWithTTL returns the obj stored in cache and its corresponding TTL.
+func (c *MetricsCache[T]) GetWithTTL(ctx context.Context, key any) (T, time.Duration, error) {
+	obj, ttl, err := c.cache.GetWithTTL(ctx, key)
+	c.stats.get(err)
+	return obj, ttl, err
+}
+
+// Set populates the cache item using the given key.
+func (c *MetricsCache[T]) Set(ctx context.Context, key any, obj T) error {
+	err := c.cache.Set(ctx, key, obj)
+	c.stats.set(err)
+	return err
+}
+
+// SetWithTTL populates the cache item using the given key and TTL.
+func (c *MetricsCache[T]) SetWithTTL(ctx context.Context, key any, obj T, ttl time.Duration) error {
+	err := c.cache.SetWithTTL(ctx, key, obj, ttl)
+	c.stats.set(err)
+	return err
+}
+
+// Del removes the cache item using the given key.
+func (c *MetricsCache[T]) Del(ctx context.Context, key any) error {
+	err := c.cache.Del(ctx, key)
+	c.stats.del(err)
+	return err
+}
+
+// GetMany returns the objs stored in cache for all the given keys. Every key
+// counts as a hit or a miss.
+func (c *MetricsCache[T]) GetMany(ctx context.Context, keys ...any) (map[any]T, error) {
+	objs, err := c.cache.GetMany(ctx, keys...)
+	c.stats.getMany(len(keys), len(objs), err)
+	return objs, err
+}
+
+// SetMany populates the cache items using the given keys and TTL.
+func (c *MetricsCache[T]) SetMany(ctx context.Context, items map[any]T, ttl time.Duration) error {
+	err := c.cache.SetMany(ctx, items, ttl)
+	c.stats.set(err)
+	return err
+}
+
+// DelMany removes the cache items using the given keys.
+func (c *MetricsCache[T]) DelMany(ctx context.Context, keys ...any) error {
+	err := c.cache.DelMany(ctx, keys...)
+	c.stats.del(err)
+	return err
+}
+
+// SetWithTags populates the cache item using the given key and TTL, and attaches the tags to it.
+func (c *MetricsCache[T]) SetWithTags(ctx context.Context, key any, obj T, ttl time.Duration, tags ...string) error {
+	err := c.cache.SetWithTags(ctx, key, obj, ttl, tags...)
+	c.stats.set(err)
+	return err
+}
+
+// InvalidateTags removes the cache items attached to any of the given tags.
+func (c *MetricsCache[T]) InvalidateTags(ctx context.Context, tags ...string) error {
+	err := c.cache.InvalidateTags(ctx, tags...)
+	c.stats.del(err)
+	return err
+}
+
+// DelByPrefix removes the cache items whose key starts with the given prefix.
+func (c *MetricsCache[T]) DelByPrefix(ctx context.Context, prefix string) error {
+	err := c.cache.DelByPrefix(ctx, prefix)
+	c.stats.del(err)
+	return err
+}
+
+// Clear resets all cache data.
+func (c *MetricsCache[T]) Clear(ctx context.Context) error {
+	return c.cache.Clear(ctx)
+}
+
+// Wait waits for all cache operations to complete.
+func (c *MetricsCache[T]) Wait(ctx context.Context) {
+	c.cache.Wait(ctx)
+}
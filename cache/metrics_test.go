@@ -0,0 +1,58 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package cache
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestMetricsStats(t *testing.T) {
+	c := NewMetrics[string]("users", newTestCache[string]())
+
+	ctx := context.Background()
+	_ = c.Set(ctx, "a", "1")
+	_, _ = c.Get(ctx, "a")
+	_, _ = c.Get(ctx, "b")
+	_ = c.Del(ctx, "a")
+
+	want := Stats{Hits: 1, Misses: 1, Sets: 1, Dels: 1}
+	if got := c.Stats(); got != want {
+		t.Fatalf("Stats() = %+v, want %+v", got, want)
+	}
+	if got := c.Stats().HitRatio(); got != 0.5 {
+		t.Errorf("HitRatio() = %v, want 0.5", got)
+	}
+}
+
+func TestRegistryWritePrometheus(t *testing.T) {
+	chain := NewChain[string](newTestCache[string](), newTestCache[string]())
+	c := NewMetrics[string]("users", chain)
+
+	ctx := context.Background()
+	_ = c.Set(ctx, "a", "1")
+	_, _ = c.Get(ctx, "a")
+
+	registry := NewRegistry()
+	if err := registry.Register(c.Name(), c); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := registry.WritePrometheus(&buf); err != nil {
+		t.Fatalf("WritePrometheus() error = %v", err)
+	}
+
+	for _, line := range []string{
+		`cache_hits_total{cache="users"} 1`,
+		`cache_sets_total{cache="users"} 1`,
+		`cache_hits_total{cache="users",layer="0"} 1`,
+		`cache_misses_total{cache="users",layer="1"} 0`,
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("WritePrometheus() output is missing %q:\n%s", line, buf.String())
+		}
+	}
+}
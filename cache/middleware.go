@@ -0,0 +1,466 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/snail-plus/gopkg/cache/store"
+	"github.com/snail-plus/gopkg/log"
+)
+
+// ErrReadOnly is returned by the writes of a cache wrapped with ReadOnly.
+var ErrReadOnly = errors.New("cache is read-only")
+
+// Middleware decorates a cache with a cross-cutting behaviour.
+type Middleware[T any] func(Cache[T]) Cache[T]
+
+// Wrap decorates the cache with the given middlewares. The first middleware
+// is the outermost one, it sees the calls first.
+//
+// Any cache can be wrapped, including the ChainCache, L2Cache and
+// LoadableCache, or the layers of a ChainCache. Note that the wrapped cache
+// only exposes the Cache interface, use Unwrap to reach the wrapped one.
+func Wrap[T any](c Cache[T], mws ...Middleware[T]) Cache[T] {
+	for i := len(mws) - 1; i >= 0; i-- {
+		c = mws[i](c)
+	}
+
+	return c
+}
+
+// Unwrap returns the cache wrapped by a middleware, or nil if c is not a middleware.
+func Unwrap[T any](c Cache[T]) Cache[T] {
+	if w, ok := c.(interface{ Unwrap() Cache[T] }); ok {
+		return w.Unwrap()
+	}
+
+	return nil
+}
+
+// wrapped is embedded by the middlewares, and passes the calls they do not
+// decorate to the wrapped cache.
+type wrapped[T any] struct {
+	Cache[T]
+}
+
+// Unwrap returns the wrapped cache.
+func (w wrapped[T]) Unwrap() Cache[T] {
+	return w.Cache
+}
+
+// Logging logs every cache operation at debug level, and the failures other
+// than misses at warning level, through the logger of the context.
+func Logging[T any]() Middleware[T] {
+	return func(c Cache[T]) Cache[T] {
+		return &loggingCache[T]{wrapped[T]{c}}
+	}
+}
+
+type loggingCache[T any] struct {
+	wrapped[T]
+}
+
+// log logs the outcome of an operation started at the given time.
+func (c *loggingCache[T]) log(ctx context.Context, op string, start time.Time, err error, keyvals ...any) {
+	keyvals = append(keyvals, "elapsed", time.Since(start))
+	if err != nil && !errors.Is(err, store.ErrKeyNotFound) {
+		log.C(ctx).Warnw(fmt.Sprintf("Cache %s failed", op), append(keyvals, "err", err)...)
+		return
+	}
+
+	log.C(ctx).Debugw(fmt.Sprintf("Cache %s", op), append(keyvals, "hit", err == nil)...)
+}
+
+func (c *loggingCache[T]) Get(ctx context.Context, key any) (T, error) {
+	start := time.Now()
+	obj, err := c.Cache.Get(ctx, key)
+	c.log(ctx, "get", start, err, "key", key)
+	return obj, err
+}
+
+func (c *loggingCache[T]) GetWithTTL(ctx context.Context, key any) (T, time.Duration, error) {
+	start := time.Now()
+	obj, ttl, err := c.Cache.GetWithTTL(ctx, key)
+	c.log(ctx, "get", start, err, "key", key, "ttl", ttl)
+	return obj, ttl, err
+}
+
+func (c *loggingCache[T]) Set(ctx context.Context, key any, obj T) error {
+	start := time.Now()
+	err := c.Cache.Set(ctx, key, obj)
+	c.log(ctx, "set", start, err, "key", key)
+	return err
+}
+
+func (c *loggingCache[T]) SetWithTTL(ctx context.Context, key any, obj T, ttl time.Duration) error {
+	start := time.Now()
+	err := c.Cache.SetWithTTL(ctx, key, obj, ttl)
+	c.log(ctx, "set", start, err, "key", key, "ttl", ttl)
+	return err
+}
+
+func (c *loggingCache[T]) Del(ctx context.Context, key any) error {
+	start := time.Now()
+	err := c.Cache.Del(ctx, key)
+	c.log(ctx, "del", start, err, "key", key)
+	return err
+}
+
+func (c *loggingCache[T]) GetMany(ctx context.Context, keys ...any) (map[any]T, error) {
+	start := time.Now()
+	objs, err := c.Cache.GetMany(ctx, keys...)
+	c.log(ctx, "get many", start, err, "keys", len(keys), "found", len(objs))
+	return objs, err
+}
+
+func (c *loggingCache[T]) SetMany(ctx context.Context, items map[any]T, ttl time.Duration) error {
+	start := time.Now()
+	err := c.Cache.SetMany(ctx, items, ttl)
+	c.log(ctx, "set many", start, err, "keys", len(items), "ttl", ttl)
+	return err
+}
+
+func (c *loggingCache[T]) DelMany(ctx context.Context, keys ...any) error {
+	start := time.Now()
+	err := c.Cache.DelMany(ctx, keys...)
+	c.log(ctx, "del many", start, err, "keys", len(keys))
+	return err
+}
+
+func (c *loggingCache[T]) SetWithTags(ctx context.Context, key any, obj T, ttl time.Duration, tags ...string) error {
+	start := time.Now()
+	err := c.Cache.SetWithTags(ctx, key, obj, ttl, tags...)
+	c.log(ctx, "set", start, err, "key", key, "ttl", ttl, "tags", tags)
+	return err
+}
+
+func (c *loggingCache[T]) InvalidateTags(ctx context.Context, tags ...string) error {
+	start := time.Now()
+	err := c.Cache.InvalidateTags(ctx, tags...)
+	c.log(ctx, "invalidate tags", start, err, "tags", tags)
+	return err
+}
+
+func (c *loggingCache[T]) DelByPrefix(ctx context.Context, prefix string) error {
+	start := time.Now()
+	err := c.Cache.DelByPrefix(ctx, prefix)
+	c.log(ctx, "del by prefix", start, err, "prefix", prefix)
+	return err
+}
+
+func (c *loggingCache[T]) Clear(ctx context.Context) error {
+	start := time.Now()
+	err := c.Cache.Clear(ctx)
+	c.log(ctx, "clear", start, err)
+	return err
+}
+
+// Timeout bounds the duration of every cache operation, Wait excepted.
+func Timeout[T any](timeout time.Duration) Middleware[T] {
+	return func(c Cache[T]) Cache[T] {
+		return &timeoutCache[T]{wrapped: wrapped[T]{c}, timeout: timeout}
+	}
+}
+
+type timeoutCache[T any] struct {
+	wrapped[T]
+	timeout time.Duration
+}
+
+func (c *timeoutCache[T]) Get(ctx context.Context, key any) (T, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return c.Cache.Get(ctx, key)
+}
+
+func (c *timeoutCache[T]) GetWithTTL(ctx context.Context, key any) (T, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return c.Cache.GetWithTTL(ctx, key)
+}
+
+func (c *timeoutCache[T]) Set(ctx context.Context, key any, obj T) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return c.Cache.Set(ctx, key, obj)
+}
+
+func (c *timeoutCache[T]) SetWithTTL(ctx context.Context, key any, obj T, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return c.Cache.SetWithTTL(ctx, key, obj, ttl)
+}
+
+func (c *timeoutCache[T]) Del(ctx context.Context, key any) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return c.Cache.Del(ctx, key)
+}
+
+func (c *timeoutCache[T]) GetMany(ctx context.Context, keys ...any) (map[any]T, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return c.Cache.GetMany(ctx, keys...)
+}
+
+func (c *timeoutCache[T]) SetMany(ctx context.Context, items map[any]T, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return c.Cache.SetMany(ctx, items, ttl)
+}
+
+func (c *timeoutCache[T]) DelMany(ctx context.Context, keys ...any) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return c.Cache.DelMany(ctx, keys...)
+}
+
+func (c *timeoutCache[T]) SetWithTags(ctx context.Context, key any, obj T, ttl time.Duration, tags ...string) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return c.Cache.SetWithTags(ctx, key, obj, ttl, tags...)
+}
+
+func (c *timeoutCache[T]) InvalidateTags(ctx context.Context, tags ...string) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return c.Cache.InvalidateTags(ctx, tags...)
+}
+
+func (c *timeoutCache[T]) DelByPrefix(ctx context.Context, prefix string) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return c.Cache.DelByPrefix(ctx, prefix)
+}
+
+func (c *timeoutCache[T]) Clear(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return c.Cache.Clear(ctx)
+}
+
+// Namespace prefixes every key and tag with the given namespace. Clear only
+// removes the keys of the namespace. To namespace a LoadableCache, wrap the
+// cache it loads into, otherwise the load function receives namespaced keys.
+func Namespace[T any](namespace string) Middleware[T] {
+	return func(c Cache[T]) Cache[T] {
+		return &namespaceCache[T]{wrapped: wrapped[T]{c}, namespace: namespace}
+	}
+}
+
+// Version prefixes every key and tag with the given version, such as "v2:".
+// Bumping the version when the cached type changes makes the objects of the
+// previous version unreachable, they are left to expire.
+func Version[T any](version int) Middleware[T] {
+	return Namespace[T](fmt.Sprintf("v%d:", version))
+}
+
+type namespaceCache[T any] struct {
+	wrapped[T]
+	namespace string
+}
+
+// key returns the namespaced key.
+func (c *namespaceCache[T]) key(key any) string {
+	return c.namespace + keyFunc(key)
+}
+
+// tags returns the namespaced tags.
+func (c *namespaceCache[T]) tags(tags []string) []string {
+	namespaced := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		namespaced = append(namespaced, c.namespace+tag)
+	}
+	return namespaced
+}
+
+func (c *namespaceCache[T]) Get(ctx context.Context, key any) (T, error) {
+	return c.Cache.Get(ctx, c.key(key))
+}
+
+func (c *namespaceCache[T]) GetWithTTL(ctx context.Context, key any) (T, time.Duration, error) {
+	return c.Cache.GetWithTTL(ctx, c.key(key))
+}
+
+func (c *namespaceCache[T]) Set(ctx context.Context, key any, obj T) error {
+	return c.Cache.Set(ctx, c.key(key), obj)
+}
+
+func (c *namespaceCache[T]) SetWithTTL(ctx context.Context, key any, obj T, ttl time.Duration) error {
+	return c.Cache.SetWithTTL(ctx, c.key(key), obj, ttl)
+}
+
+func (c *namespaceCache[T]) Del(ctx context.Context, key any) error {
+	return c.Cache.Del(ctx, c.key(key))
+}
+
+func (c *namespaceCache[T]) GetMany(ctx context.Context, keys ...any) (map[any]T, error) {
+	namespaced := make([]any, 0, len(keys))
+	byNamespaced := make(map[string]any, len(keys))
+	for _, key := range keys {
+		k := c.key(key)
+		namespaced = append(namespaced, k)
+		byNamespaced[k] = key
+	}
+
+	found, err := c.Cache.GetMany(ctx, namespaced...)
+	objs := make(map[any]T, len(found))
+	for k, obj := range found {
+		objs[byNamespaced[k.(string)]] = obj
+	}
+
+	return objs, err
+}
+
+func (c *namespaceCache[T]) SetMany(ctx context.Context, items map[any]T, ttl time.Duration) error {
+	namespaced := make(map[any]T, len(items))
+	for key, obj := range items {
+		namespaced[c.key(key)] = obj
+	}
+
+	return c.Cache.SetMany(ctx, namespaced, ttl)
+}
+
+func (c *namespaceCache[T]) DelMany(ctx context.Context, keys ...any) error {
+	namespaced := make([]any, 0, len(keys))
+	for _, key := range keys {
+		namespaced = append(namespaced, c.key(key))
+	}
+
+	return c.Cache.DelMany(ctx, namespaced...)
+}
+
+func (c *namespaceCache[T]) SetWithTags(ctx context.Context, key any, obj T, ttl time.Duration, tags ...string) error {
+	return c.Cache.SetWithTags(ctx, c.key(key), obj, ttl, c.tags(tags)...)
+}
+
+func (c *namespaceCache[T]) InvalidateTags(ctx context.Context, tags ...string) error {
+	return c.Cache.InvalidateTags(ctx, c.tags(tags)...)
+}
+
+func (c *namespaceCache[T]) DelByPrefix(ctx context.Context, prefix string) error {
+	return c.Cache.DelByPrefix(ctx, c.namespace+prefix)
+}
+
+func (c *namespaceCache[T]) Clear(ctx context.Context) error {
+	return c.Cache.DelByPrefix(ctx, c.namespace)
+}
+
+// TTLJitter extends every TTL by a random duration of up to the given
+// fraction of it, so that objects written together do not expire together.
+// Writes without a TTL are left untouched. The objects of a SetMany call
+// share the same jitter.
+func TTLJitter[T any](fraction float64) Middleware[T] {
+	return func(c Cache[T]) Cache[T] {
+		return &jitterCache[T]{wrapped: wrapped[T]{c}, fraction: fraction}
+	}
+}
+
+type jitterCache[T any] struct {
+	wrapped[T]
+	fraction float64
+}
+
+// ttl returns the TTL extended by a random jitter.
+func (c *jitterCache[T]) ttl(ttl time.Duration) time.Duration {
+	maxJitter := int64(float64(ttl) * c.fraction)
+	if ttl <= 0 || maxJitter <= 0 {
+		return ttl
+	}
+
+	return ttl + time.Duration(rand.Int64N(maxJitter+1))
+}
+
+func (c *jitterCache[T]) SetWithTTL(ctx context.Context, key any, obj T, ttl time.Duration) error {
+	return c.Cache.SetWithTTL(ctx, key, obj, c.ttl(ttl))
+}
+
+func (c *jitterCache[T]) SetMany(ctx context.Context, items map[any]T, ttl time.Duration) error {
+	return c.Cache.SetMany(ctx, items, c.ttl(ttl))
+}
+
+func (c *jitterCache[T]) SetWithTags(ctx context.Context, key any, obj T, ttl time.Duration, tags ...string) error {
+	return c.Cache.SetWithTags(ctx, key, obj, c.ttl(ttl), tags...)
+}
+
+// ReadOnly rejects every write, deletions included, with ErrReadOnly. It is
+// meant to freeze a cache during an incident.
+func ReadOnly[T any]() Middleware[T] {
+	return func(c Cache[T]) Cache[T] {
+		return &readOnlyCache[T]{wrapped[T]{c}}
+	}
+}
+
+type readOnlyCache[T any] struct {
+	wrapped[T]
+}
+
+func (c *readOnlyCache[T]) Set(context.Context, any, T) error {
+	return ErrReadOnly
+}
+
+func (c *readOnlyCache[T]) SetWithTTL(context.Context, any, T, time.Duration) error {
+	return ErrReadOnly
+}
+
+func (c *readOnlyCache[T]) Del(context.Context, any) error {
+	return ErrReadOnly
+}
+
+func (c *readOnlyCache[T]) SetMany(context.Context, map[any]T, time.Duration) error {
+	return ErrReadOnly
+}
+
+func (c *readOnlyCache[T]) DelMany(context.Context, ...any) error {
+	return ErrReadOnly
+}
+
+func (c *readOnlyCache[T]) SetWithTags(context.Context, any, T, time.Duration, ...string) error {
+	return ErrReadOnly
+}
+
+func (c *readOnlyCache[T]) InvalidateTags(context.Context, ...string) error {
+	return ErrReadOnly
+}
+
+func (c *readOnlyCache[T]) DelByPrefix(context.Context, string) error {
+	return ErrReadOnly
+}
+
+func (c *readOnlyCache[T]) Clear(context.Context) error {
+	return ErrReadOnly
+}
+
+// WriteDisabled silently skips every write, while deletions still go through
+// so that no stale object is served once writes are enabled again. It is
+// meant to relieve an overloaded backend during an incident.
+func WriteDisabled[T any]() Middleware[T] {
+	return func(c Cache[T]) Cache[T] {
+		return &writeDisabledCache[T]{wrapped[T]{c}}
+	}
+}
+
+type writeDisabledCache[T any] struct {
+	wrapped[T]
+}
+
+func (c *writeDisabledCache[T]) Set(context.Context, any, T) error {
+	return nil
+}
+
+func (c *writeDisabledCache[T]) SetWithTTL(context.Context, any, T, time.Duration) error {
+	return nil
+}
+
+func (c *writeDisabledCache[T]) SetMany(context.Context, map[any]T, time.Duration) error {
+	return nil
+}
+
+func (c *writeDisabledCache[T]) SetWithTags(context.Context, any, T, time.Duration, ...string) error {
+	return nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWrap(t *testing.T) {
+	ctx := context.Background()
+	inner := newTestCache[string]()
+	c := Wrap[string](inner, Logging[string](), Version[string](2), TTLJitter[string](0.5))
+
+	if err := c.SetWithTTL(ctx, "key", "value", time.Minute); err != nil {
+		t.Fatalf("SetWithTTL() error = %v", err)
+	}
+	if obj, err := c.Get(ctx, "key"); err != nil || obj != "value" {
+		t.Fatalf("Get() = %q, %v, want value", obj, err)
+	}
+
+	// The key is versioned and its TTL extended by at most half.
+	_, ttl, err := inner.GetWithTTL(ctx, "v2:key")
+	if err != nil {
+		t.Fatalf("GetWithTTL() error = %v, want the versioned key", err)
+	}
+	if ttl < time.Minute-time.Second || ttl > 90*time.Second {
+		t.Errorf("GetWithTTL() ttl = %v, want between 1m and 1m30s", ttl)
+	}
+
+	objs, err := c.GetMany(ctx, "key", "missing")
+	if err != nil || len(objs) != 1 || objs["key"] != "value" {
+		t.Errorf("GetMany() = %v, %v, want the unversioned key", objs, err)
+	}
+
+	if Unwrap(c) == nil {
+		t.Errorf("Unwrap() = nil, want the wrapped cache")
+	}
+}
+
+func TestReadOnlyAndWriteDisabled(t *testing.T) {
+	ctx := context.Background()
+	inner := newTestCache[string]()
+	_ = inner.Set(ctx, "key", "value")
+
+	readOnly := Wrap[string](inner, ReadOnly[string]())
+	if err := readOnly.Set(ctx, "other", "value"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Set() error = %v, want %v", err, ErrReadOnly)
+	}
+	if err := readOnly.Del(ctx, "key"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Del() error = %v, want %v", err, ErrReadOnly)
+	}
+	if obj, err := readOnly.Get(ctx, "key"); err != nil || obj != "value" {
+		t.Errorf("Get() = %q, %v, want value", obj, err)
+	}
+
+	writeDisabled := Wrap[string](inner, WriteDisabled[string]())
+	if err := writeDisabled.Set(ctx, "other", "value"); err != nil {
+		t.Errorf("Set() error = %v, want the write skipped", err)
+	}
+	if _, err := inner.Get(ctx, "other"); err == nil {
+		t.Errorf("Get() found a skipped write")
+	}
+	if err := writeDisabled.Del(ctx, "key"); err != nil {
+		t.Errorf("Del() error = %v", err)
+	}
+	if _, err := inner.Get(ctx, "key"); err == nil {
+		t.Errorf("Get() found a deleted key")
+	}
+}
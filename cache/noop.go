@@ -0,0 +1,83 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/snail-plus/gopkg/cache/store"
+)
+
+// NoopCache is a cache that holds nothing: every read is a miss and every
+// write succeeds without storing anything. It disables caching without
+// changing the code using the cache.
+type NoopCache[T any] struct{}
+
+// NewNoop instantiates a new cache that holds nothing.
+func NewNoop[T any]() *NoopCache[T] {
+	return &NoopCache[T]{}
+}
+
+// Get always returns store.ErrKeyNotFound.
+func (c *NoopCache[T]) Get(_ context.Context, _ any) (T, error) {
+	return *new(T), store.ErrKeyNotFound
+}
+
+// GetWithTTL always returns store.ErrKeyNotFound.
+func (c *NoopCache[T]) GetWithTTL(_ context.Context, _ any) (T, time.Duration, error) {
+	return *new(T), 0, store.ErrKeyNotFound
+}
+
+// Set does nothing.
+func (c *NoopCache[T]) Set(_ context.Context, _ any, _ T) error {
+	return nil
+}
+
+// SetWithTTL does nothing.
+func (c *NoopCache[T]) SetWithTTL(_ context.Context, _ any, _ T, _ time.Duration) error {
+	return nil
+}
+
+// Del does nothing.
+func (c *NoopCache[T]) Del(_ context.Context, _ any) error {
+	return nil
+}
+
+// GetMany always returns an empty map.
+func (c *NoopCache[T]) GetMany(_ context.Context, _ ...any) (map[any]T, error) {
+	return map[any]T{}, nil
+}
+
+// SetMany does nothing.
+func (c *NoopCache[T]) SetMany(_ context.Context, _ map[any]T, _ time.Duration) error {
+	return nil
+}
+
+// DelMany does nothing.
+func (c *NoopCache[T]) DelMany(_ context.Context, _ ...any) error {
+	return nil
+}
+
+// SetWithTags does nothing.
+func (c *NoopCache[T]) SetWithTags(_ context.Context, _ any, _ T, _ time.Duration, _ ...string) error {
+	return nil
+}
+
+// InvalidateTags does nothing.
+func (c *NoopCache[T]) InvalidateTags(_ context.Context, _ ...string) error {
+	return nil
+}
+
+// DelByPrefix does nothing.
+func (c *NoopCache[T]) DelByPrefix(_ context.Context, _ string) error {
+	return nil
+}
+
+// Clear does nothing.
+func (c *NoopCache[T]) Clear(_ context.Context) error {
+	return nil
+}
+
+// Wait returns immediately.
+func (c *NoopCache[T]) Wait(_ context.Context) {}
@@ -0,0 +1,127 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package cache
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultRegistry is the registry used by callers that do not need their own.
+var DefaultRegistry = NewRegistry()
+
+// metricFamily describes one exported metric.
+type metricFamily struct {
+	name  string
+	help  string
+	typ   string
+	value func(s Stats) string
+}
+
+var metricFamilies = []metricFamily{
+	{"cache_hits_total", "Number of cache reads that found the object.", "counter", func(s Stats) string { return fmt.Sprint(s.Hits) }},
+	{"cache_misses_total", "Number of cache reads that did not find the object.", "counter", func(s Stats) string { return fmt.Sprint(s.Misses) }},
+	{"cache_sets_total", "Number of successful cache writes.", "counter", func(s Stats) string { return fmt.Sprint(s.Sets) }},
+	{"cache_dels_total", "Number of successful cache deletions.", "counter", func(s Stats) string { return fmt.Sprint(s.Dels) }},
+	{"cache_errors_total", "Number of failed cache operations.", "counter", func(s Stats) string { return fmt.Sprint(s.Errors) }},
+	{"cache_loads_total", "Number of objects loaded on a cache miss.", "counter", func(s Stats) string { return fmt.Sprint(s.Loads) }},
+	{"cache_load_errors_total", "Number of failed loads.", "counter", func(s Stats) string { return fmt.Sprint(s.LoadErrors) }},
+	{"cache_load_seconds_total", "Total time spent loading objects.", "counter", func(s Stats) string { return fmt.Sprint(s.LoadTime.Seconds()) }},
+}
+
+// Registry holds named caches and exports their statistics in the Prometheus
+// text exposition format.
+type Registry struct {
+	mu     sync.RWMutex
+	caches map[string]any
+}
+
+// NewRegistry instantiates a new, empty registry.
+func NewRegistry() *Registry {
+	return &Registry{caches: make(map[string]any)}
+}
+
+// Register adds a cache under the given name, replacing any cache previously
+// registered under that name. The cache must implement StatsProvider,
+// LayeredStatsProvider or both.
+func (r *Registry) Register(name string, cache any) error {
+	_, isStats := cache.(StatsProvider)
+	_, isLayered := cache.(LayeredStatsProvider)
+	if !isStats && !isLayered {
+		return fmt.Errorf("cache %q does not keep statistics", name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.caches[name] = cache
+
+	return nil
+}
+
+// Unregister removes the cache registered under the given name.
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.caches, name)
+}
+
+// WritePrometheus writes the statistics of all registered caches to w.
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.caches))
+	caches := make(map[string]any, len(r.caches))
+	for name, cache := range r.caches {
+		names = append(names, name)
+		caches[name] = cache
+	}
+	r.mu.RUnlock()
+
+	sort.Strings(names)
+
+	// Take every snapshot once, so all families of a cache are consistent.
+	stats := make(map[string]Stats, len(names))
+	layers := make(map[string][]LayerStats, len(names))
+	for _, name := range names {
+		if provider, ok := caches[name].(StatsProvider); ok {
+			stats[name] = provider.Stats()
+		}
+		if provider, ok := caches[name].(LayeredStatsProvider); ok {
+			layers[name] = provider.LayerStats()
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, family := range metricFamilies {
+		fmt.Fprintf(bw, "# HELP %s %s\n", family.name, family.help)
+		fmt.Fprintf(bw, "# TYPE %s %s\n", family.name, family.typ)
+		for _, name := range names {
+			if s, ok := stats[name]; ok {
+				fmt.Fprintf(bw, "%s{cache=\"%s\"} %s\n", family.name, escapeLabel(name), family.value(s))
+			}
+			for _, layer := range layers[name] {
+				fmt.Fprintf(bw, "%s{cache=\"%s\",layer=\"%s\"} %s\n",
+					family.name, escapeLabel(name), escapeLabel(layer.Layer), family.value(layer.Stats))
+			}
+		}
+	}
+
+	return bw.Flush()
+}
+
+// ServeHTTP serves the statistics of all registered caches to Prometheus.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = r.WritePrometheus(w)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabel escapes a Prometheus label value.
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
@@ -0,0 +1,169 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package cache
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/snail-plus/gopkg/cache/store"
+)
+
+// Stats is a point-in-time snapshot of cache statistics.
+type Stats struct {
+	// Hits is the number of reads that found the object.
+	Hits uint64
+	// Misses is the number of reads that did not find the object.
+	Misses uint64
+	// Sets is the number of successful writes.
+	Sets uint64
+	// Dels is the number of successful deletions.
+	Dels uint64
+	// Errors is the number of operations that failed for a reason other than a miss.
+	Errors uint64
+	// Loads is the number of objects loaded because they were missing from the cache.
+	Loads uint64
+	// LoadErrors is the number of loads that failed.
+	LoadErrors uint64
+	// LoadTime is the total time spent loading objects.
+	LoadTime time.Duration
+}
+
+// HitRatio returns the ratio of hits to reads, or 0 if there were no reads.
+func (s Stats) HitRatio() float64 {
+	reads := s.Hits + s.Misses
+	if reads == 0 {
+		return 0
+	}
+
+	return float64(s.Hits) / float64(reads)
+}
+
+// AvgLoadTime returns the average time spent per load, or 0 if nothing was loaded.
+func (s Stats) AvgLoadTime() time.Duration {
+	if s.Loads == 0 {
+		return 0
+	}
+
+	return s.LoadTime / time.Duration(s.Loads)
+}
+
+// LayerStats holds the statistics of one layer of a multi-level cache.
+type LayerStats struct {
+	// Layer identifies the layer, for example "local" or "remote".
+	Layer string
+	Stats
+}
+
+// StatsProvider is implemented by caches that keep statistics.
+type StatsProvider interface {
+	// Stats returns a snapshot of the cache statistics.
+	Stats() Stats
+}
+
+// LayeredStatsProvider is implemented by multi-level caches that keep statistics per layer.
+type LayeredStatsProvider interface {
+	// LayerStats returns a snapshot of the statistics of every layer.
+	LayerStats() []LayerStats
+}
+
+// statsCounter records cache statistics. A nil *statsCounter records nothing,
+// which is how statistics are disabled.
+type statsCounter struct {
+	hits       atomic.Uint64
+	misses     atomic.Uint64
+	sets       atomic.Uint64
+	dels       atomic.Uint64
+	errors     atomic.Uint64
+	loads      atomic.Uint64
+	loadErrors atomic.Uint64
+	loadTime   atomic.Int64
+}
+
+// get records the outcome of a read.
+func (s *statsCounter) get(err error) {
+	if s == nil {
+		return
+	}
+
+	switch {
+	case err == nil:
+		s.hits.Add(1)
+	case errors.Is(err, store.ErrKeyNotFound):
+		s.misses.Add(1)
+	default:
+		s.errors.Add(1)
+	}
+}
+
+// getMany records the outcome of a bulk read of the given number of keys.
+func (s *statsCounter) getMany(keys, found int, err error) {
+	if s == nil {
+		return
+	}
+
+	if err != nil {
+		s.errors.Add(1)
+		return
+	}
+	s.hits.Add(uint64(found))
+	s.misses.Add(uint64(keys - found))
+}
+
+// set records the outcome of a write.
+func (s *statsCounter) set(err error) {
+	if s == nil {
+		return
+	}
+
+	if err != nil {
+		s.errors.Add(1)
+		return
+	}
+	s.sets.Add(1)
+}
+
+// del records the outcome of a deletion.
+func (s *statsCounter) del(err error) {
+	if s == nil {
+		return
+	}
+
+	if err != nil {
+		s.errors.Add(1)
+		return
+	}
+	s.dels.Add(1)
+}
+
+// load records the outcome and duration of a load.
+func (s *statsCounter) load(elapsed time.Duration, err error) {
+	if s == nil {
+		return
+	}
+
+	s.loads.Add(1)
+	s.loadTime.Add(int64(elapsed))
+	if err != nil {
+		s.loadErrors.Add(1)
+	}
+}
+
+// snapshot returns the recorded statistics.
+func (s *statsCounter) snapshot() Stats {
+	if s == nil {
+		return Stats{}
+	}
+
+	return Stats{
+		Hits:       s.hits.Load(),
+		Misses:     s.misses.Load(),
+		Sets:       s.sets.Load(),
+		Dels:       s.dels.Load(),
+		Errors:     s.errors.Load(),
+		Loads:      s.loads.Load(),
+		LoadErrors: s.loadErrors.Load(),
+		LoadTime:   time.Duration(s.loadTime.Load()),
+	}
+}
@@ -0,0 +1,566 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package disk
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snail-plus/gopkg/cache/store"
+)
+
+const (
+	// DiskType represents the storage type as a string value.
+	DiskType = "disk"
+
+	// tmpPrefix is the name prefix of the files being written.
+	tmpPrefix = ".tmp-"
+)
+
+var (
+	// ErrUnsupportedValue is returned when writing a value that is neither a byte slice nor a string.
+	ErrUnsupportedValue = errors.New("disk store only holds byte slices and strings")
+	// ErrEntryTooLarge is returned when the size of an entry exceeds Options.MaxBytes.
+	ErrEntryTooLarge = errors.New("entry size exceeds the store quota")
+)
+
+// diskEntry describes an entry file.
+type diskEntry struct {
+	key       string
+	path      string
+	size      int64
+	expiresAt time.Time
+	// elem is the element of the entry in the LRU list.
+	elem *list.Element
+}
+
+// expired reports whether the entry has reached its TTL at the given time.
+func (e *diskEntry) expired(now time.Time) bool {
+	return expired(e.expiresAt, now)
+}
+
+// expired reports whether an expiration time, zero meaning never, is reached at the given time.
+func expired(expiresAt, now time.Time) bool {
+	return !expiresAt.IsZero() && !now.Before(expiresAt)
+}
+
+// DiskStore is a store keeping every entry in its own file under a directory.
+// Values are byte slices, the objects of a cache must be serialized with a
+// codec, see cache.DelegateWithCodec. Files are written to a temporary file
+// then renamed, so a crash never leaves a partially written entry behind.
+//
+// The store keeps an index of the entries in memory, which is rebuilt from
+// the entry files when the store is opened. A directory must not be shared by
+// several stores at the same time.
+type DiskStore struct {
+	dir  string
+	opts *Options
+
+	mu      sync.Mutex
+	entries map[string]*diskEntry
+	// lru orders the entries from the most to the least recently used.
+	lru   *list.List
+	bytes int64
+	index *store.Index
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewDisk opens a store persisting its entries under the given directory,
+// which is created if needed. Close must be called to stop the janitor once
+// the store is no longer used.
+func NewDisk(dir string, options ...Option) (*DiskStore, error) {
+	opts := NewOptions()
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("unable to create cache directory: %w", err)
+	}
+
+	s := &DiskStore{
+		dir:     dir,
+		opts:    opts,
+		entries: make(map[string]*diskEntry),
+		lru:     list.New(),
+		index:   store.NewIndex(),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	if opts.CleanupInterval > 0 {
+		go s.janitor(opts.CleanupInterval)
+	} else {
+		close(s.done)
+	}
+
+	return s, nil
+}
+
+// load rebuilds the index from the entry files. Leftover temporary files,
+// expired and corrupted entries are removed. Only the files laid out and
+// named like the ones written by the store are considered, and a file not
+// starting with the magic of the entry files is left alone, so foreign files
+// of the directory are never removed. Entries are ordered by modification
+// time, since reads before the restart are not known.
+func (s *DiskStore) load() error {
+	type loaded struct {
+		entry   *diskEntry
+		tags    []string
+		modTime time.Time
+	}
+
+	now := time.Now()
+	var files []loaded
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if rel != "." && !isShardDir(rel) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !isShardDir(filepath.Dir(rel)) {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), tmpPrefix) {
+			return os.Remove(path)
+		}
+		if !isEntryName(d.Name()) {
+			return nil
+		}
+
+		h, _, err := readFile(path, false)
+		if errors.Is(err, errForeign) {
+			return nil
+		}
+		if errors.Is(err, errCorrupted) {
+			return os.Remove(path)
+		}
+		if err != nil {
+			return err
+		}
+		if s.path(h.key) != path || expired(h.expiresAt, now) {
+			return os.Remove(path)
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		files = append(files, loaded{
+			entry:   &diskEntry{key: h.key, path: path, size: info.Size(), expiresAt: h.expiresAt},
+			tags:    h.tags,
+			modTime: info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to load cache directory: %w", err)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, f := range files {
+		f.entry.elem = s.lru.PushFront(f.entry)
+		s.entries[f.entry.key] = f.entry
+		s.bytes += f.entry.size
+		s.index.Add(f.entry.key, f.tags...)
+	}
+
+	// The quota may have been lowered since the entries were written.
+	return s.evict(nil)
+}
+
+// path returns the path of the entry file of the given key. Files are spread
+// over subdirectories to keep directories small.
+func (s *DiskStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(s.dir, name[:2], name)
+}
+
+// isShardDir reports whether the path, relative to the store directory, names
+// one of the subdirectories holding the entry files.
+func isShardDir(rel string) bool {
+	return len(rel) == 2 && isHex(rel)
+}
+
+// isEntryName reports whether the file name is the name of an entry file.
+func isEntryName(name string) bool {
+	return len(name) == 2*sha256.Size && isHex(name)
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Get returns data stored from a given key.
+func (s *DiskStore) Get(_ context.Context, key any) (any, error) {
+	value, _, err := s.get(key.(string))
+	return value, err
+}
+
+// GetWithTTL returns data stored from a given key and its corresponding TTL,
+// zero meaning it does not expire.
+func (s *DiskStore) GetWithTTL(_ context.Context, key any) (any, time.Duration, error) {
+	return s.get(key.(string))
+}
+
+// get reads the value and the remaining TTL of the key.
+func (s *DiskStore) get(key string) ([]byte, time.Duration, error) {
+	s.mu.Lock()
+	e, ok := s.entries[key]
+	if !ok {
+		s.mu.Unlock()
+		return nil, 0, store.ErrKeyNotFound
+	}
+
+	now := time.Now()
+	if e.expired(now) {
+		err := s.remove(e)
+		s.mu.Unlock()
+		if err != nil {
+			return nil, 0, err
+		}
+		return nil, 0, store.ErrKeyNotFound
+	}
+	s.lru.MoveToFront(e.elem)
+	s.mu.Unlock()
+
+	// The file is read without holding the lock. Since files are replaced by
+	// renames, it is either the previous or the new version of the entry.
+	h, value, err := readFile(e.path, true)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, 0, store.ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	if h.key != key {
+		return nil, 0, store.ErrKeyNotFound
+	}
+
+	if expired(h.expiresAt, now) {
+		return nil, 0, store.ErrKeyNotFound
+	}
+
+	var ttl time.Duration
+	if !h.expiresAt.IsZero() {
+		ttl = h.expiresAt.Sub(now)
+	}
+
+	return value, ttl, nil
+}
+
+// Set defines data on disk for given key identifier.
+func (s *DiskStore) Set(ctx context.Context, key any, value any) error {
+	return s.SetWithTags(ctx, key, value, 0)
+}
+
+// SetWithTTL defines data on disk for given key identifier with the given TTL.
+func (s *DiskStore) SetWithTTL(ctx context.Context, key any, value any, ttl time.Duration) error {
+	return s.SetWithTags(ctx, key, value, ttl)
+}
+
+// SetWithTags defines data on disk for given key identifier and attaches the
+// tags to it. Tags are persisted along with the value.
+func (s *DiskStore) SetWithTags(_ context.Context, key any, value any, ttl time.Duration, tags ...string) error {
+	var data []byte
+	switch typed := value.(type) {
+	case []byte:
+		data = typed
+	case string:
+		data = []byte(typed)
+	default:
+		return fmt.Errorf("%w: got %T", ErrUnsupportedValue, value)
+	}
+
+	h := &header{key: key.(string), tags: tags}
+	if ttl > 0 {
+		h.expiresAt = time.Now().Add(ttl)
+	}
+	head := h.marshal()
+
+	size := int64(len(head) + len(data))
+	if s.opts.MaxBytes > 0 && size > s.opts.MaxBytes {
+		return ErrEntryTooLarge
+	}
+
+	path := s.path(h.key)
+	tmp, err := s.writeTemp(filepath.Dir(path), head, data)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("unable to write cache entry: %w", err)
+	}
+
+	e, ok := s.entries[h.key]
+	if ok {
+		s.bytes += size - e.size
+		e.size, e.expiresAt = size, h.expiresAt
+		s.lru.MoveToFront(e.elem)
+	} else {
+		e = &diskEntry{key: h.key, path: path, size: size, expiresAt: h.expiresAt}
+		e.elem = s.lru.PushFront(e)
+		s.entries[h.key] = e
+		s.bytes += size
+	}
+	s.index.Add(h.key, tags...)
+
+	return s.evict(e)
+}
+
+// writeTemp writes the entry to a new temporary file in the given directory and returns its path.
+func (s *DiskStore) writeTemp(dir string, head, data []byte) (string, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("unable to create cache directory: %w", err)
+	}
+
+	f, err := os.CreateTemp(dir, tmpPrefix+"*")
+	if err != nil {
+		return "", fmt.Errorf("unable to write cache entry: %w", err)
+	}
+
+	_, err = f.Write(head)
+	if err == nil {
+		_, err = f.Write(data)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return "", fmt.Errorf("unable to write cache entry: %w", err)
+	}
+
+	return f.Name(), nil
+}
+
+// evict removes the least recently used entries other than the protected one
+// until the store fits its quota, s.mu must be held.
+func (s *DiskStore) evict(protected *diskEntry) error {
+	for s.opts.MaxBytes > 0 && s.bytes > s.opts.MaxBytes {
+		elem := s.lru.Back()
+		if elem != nil && elem.Value.(*diskEntry) == protected {
+			elem = elem.Prev()
+		}
+		if elem == nil {
+			return nil
+		}
+
+		if err := s.remove(elem.Value.(*diskEntry)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Del removes data on disk for given key identifier.
+func (s *DiskStore) Del(_ context.Context, key any) error {
+	return s.del(key.(string))
+}
+
+// GetMany returns data stored from the given keys.
+func (s *DiskStore) GetMany(_ context.Context, keys ...any) (map[any]any, error) {
+	values := make(map[any]any, len(keys))
+	for _, key := range keys {
+		value, _, err := s.get(key.(string))
+		if errors.Is(err, store.ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return values, err
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// SetMany defines data on disk for all given key identifiers.
+func (s *DiskStore) SetMany(ctx context.Context, items map[any]any, ttl time.Duration) error {
+	for key, value := range items {
+		if err := s.SetWithTags(ctx, key, value, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DelMany removes data on disk for all given key identifiers.
+func (s *DiskStore) DelMany(_ context.Context, keys ...any) error {
+	strKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		strKeys = append(strKeys, key.(string))
+	}
+	return s.del(strKeys...)
+}
+
+// InvalidateTags removes data attached to any of the given tags.
+func (s *DiskStore) InvalidateTags(_ context.Context, tags ...string) error {
+	return s.del(s.index.Tagged(tags...)...)
+}
+
+// DelByPrefix removes data whose key starts with the given prefix.
+func (s *DiskStore) DelByPrefix(_ context.Context, prefix string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, e := range s.entries {
+		if strings.HasPrefix(key, prefix) {
+			if err := s.remove(e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// del removes the given keys.
+func (s *DiskStore) del(keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		if e, ok := s.entries[key]; ok {
+			if err := s.remove(e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Clear removes all data in the store.
+func (s *DiskStore) Clear(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range s.entries {
+		if err := s.remove(e); err != nil {
+			return err
+		}
+	}
+	s.index.Clear()
+	return nil
+}
+
+func (s *DiskStore) Wait(_ context.Context) {
+}
+
+// Len returns the number of entries in the store.
+func (s *DiskStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.entries)
+}
+
+// Bytes returns the total size of the entry files.
+func (s *DiskStore) Bytes() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.bytes
+}
+
+// Close stops the janitor. The entry files are kept, and are loaded again by
+// the next store opened on the same directory.
+func (s *DiskStore) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+	})
+	<-s.done
+
+	return nil
+}
+
+// janitor periodically removes the expired entries until the store is closed.
+func (s *DiskStore) janitor(interval time.Duration) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_ = s.deleteExpired()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// deleteExpired removes the expired entries.
+func (s *DiskStore) deleteExpired() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, e := range s.entries {
+		if e.expired(now) {
+			if err := s.remove(e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// remove deletes the entry file and forgets the entry, s.mu must be held.
+func (s *DiskStore) remove(e *diskEntry) error {
+	if err := os.Remove(e.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("unable to remove cache entry: %w", err)
+	}
+
+	delete(s.entries, e.key)
+	s.lru.Remove(e.elem)
+	s.bytes -= e.size
+	s.index.Remove(e.key)
+	return nil
+}
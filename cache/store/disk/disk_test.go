@@ -0,0 +1,146 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package disk
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/snail-plus/gopkg/cache/store"
+)
+
+var _ store.Store = (*DiskStore)(nil)
+
+func TestDiskPersistence(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s, err := NewDisk(dir)
+	if err != nil {
+		t.Fatalf("NewDisk() error = %v", err)
+	}
+	if err := s.SetWithTags(ctx, "report:1", []byte("payload"), time.Hour, "reports"); err != nil {
+		t.Fatalf("SetWithTags() error = %v", err)
+	}
+	if err := s.SetWithTTL(ctx, "short", "value", time.Millisecond); err != nil {
+		t.Fatalf("SetWithTTL() error = %v", err)
+	}
+	if err := s.Set(ctx, "object", struct{}{}); !errors.Is(err, ErrUnsupportedValue) {
+		t.Errorf("Set() error = %v, want %v", err, ErrUnsupportedValue)
+	}
+	_ = s.Close()
+
+	time.Sleep(5 * time.Millisecond)
+
+	// A new store on the same directory finds the entries and their tags.
+	s, err = NewDisk(dir)
+	if err != nil {
+		t.Fatalf("NewDisk() error = %v", err)
+	}
+	defer s.Close()
+
+	value, ttl, err := s.GetWithTTL(ctx, "report:1")
+	if err != nil || string(value.([]byte)) != "payload" {
+		t.Fatalf("GetWithTTL() = %v, %v, want payload", value, err)
+	}
+	if ttl <= 0 || ttl > time.Hour {
+		t.Errorf("GetWithTTL() ttl = %v, want at most an hour", ttl)
+	}
+	if _, err := s.Get(ctx, "short"); !errors.Is(err, store.ErrKeyNotFound) {
+		t.Errorf("Get(short) error = %v, want it expired", err)
+	}
+
+	if err := s.InvalidateTags(ctx, "reports"); err != nil {
+		t.Fatalf("InvalidateTags() error = %v", err)
+	}
+	if _, err := s.Get(ctx, "report:1"); !errors.Is(err, store.ErrKeyNotFound) {
+		t.Errorf("Get(report:1) error = %v, want it invalidated", err)
+	}
+	if s.Len() != 0 || s.Bytes() != 0 {
+		t.Errorf("Len() = %d, Bytes() = %d, want an empty store", s.Len(), s.Bytes())
+	}
+}
+
+func TestDiskForeignFiles(t *testing.T) {
+	dir := t.TempDir()
+	entryName := strings.Repeat("ab", 32)
+
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	foreign := []string{
+		write("config.yaml", []byte("foreign")),
+		write(".tmp-upload", []byte("foreign")),
+		write("data/"+entryName, []byte("foreign")),
+		write("ab/notes.txt", []byte("foreign")),
+		write("ab/"+entryName, []byte("foreign")),
+	}
+	// Leftovers of the store itself are removed.
+	leftovers := []string{
+		write("cd/"+tmpPrefix+"123", []byte("partial")),
+		write("cd/"+strings.Repeat("cd", 32), magic[:]),
+	}
+
+	s, err := NewDisk(dir)
+	if err != nil {
+		t.Fatalf("NewDisk() error = %v", err)
+	}
+	defer s.Close()
+
+	for _, path := range foreign {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("foreign file %s removed: %v", path, err)
+		}
+	}
+	for _, path := range leftovers {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("leftover file %s kept: %v", path, err)
+		}
+	}
+	if s.Len() != 0 {
+		t.Errorf("Len() = %d, want no entry loaded", s.Len())
+	}
+}
+
+func TestDiskQuota(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewDisk(t.TempDir(), WithMaxBytes(300))
+	if err != nil {
+		t.Fatalf("NewDisk() error = %v", err)
+	}
+	defer s.Close()
+
+	value := make([]byte, 100)
+	for _, key := range []string{"a", "b", "c"} {
+		if err := s.Set(ctx, key, value); err != nil {
+			t.Fatalf("Set(%q) error = %v", key, err)
+		}
+	}
+
+	// Headers take some room as well, so only two entries fit.
+	if _, err := s.Get(ctx, "a"); !errors.Is(err, store.ErrKeyNotFound) {
+		t.Errorf("Get(a) error = %v, want it evicted", err)
+	}
+	if _, err := s.Get(ctx, "c"); err != nil {
+		t.Errorf("Get(c) error = %v", err)
+	}
+	if s.Bytes() > 300 {
+		t.Errorf("Bytes() = %d, want at most 300", s.Bytes())
+	}
+	if err := s.Set(ctx, "huge", make([]byte, 400)); !errors.Is(err, ErrEntryTooLarge) {
+		t.Errorf("Set(huge) error = %v, want %v", err, ErrEntryTooLarge)
+	}
+}
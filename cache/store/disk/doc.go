@@ -0,0 +1,5 @@
+// Copyright 2024 eve.  All rights reserved.
+
+// Package disk provides a store persisting entries as files under a
+// directory, meant as a large and slow tier that survives restarts.
+package disk // import "github.com/snail-plus/gopkg/cache/store/disk"
@@ -0,0 +1,136 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package disk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// magic identifies the entry files written by this package, and their format version.
+var magic = [4]byte{'g', 'k', 'd', 1}
+
+var (
+	// errCorrupted is returned when an entry file does not hold a valid header.
+	errCorrupted = errors.New("corrupted cache entry file")
+	// errForeign is returned when a file does not start with the magic of
+	// the entry files, so it was not written by this package.
+	errForeign = errors.New("not a cache entry file")
+)
+
+// header is the metadata stored at the beginning of every entry file, before the value.
+type header struct {
+	key       string
+	tags      []string
+	expiresAt time.Time
+}
+
+// marshal encodes the header.
+func (h *header) marshal() []byte {
+	var buf bytes.Buffer
+	buf.Write(magic[:])
+
+	var expiresAt int64
+	if !h.expiresAt.IsZero() {
+		expiresAt = h.expiresAt.UnixNano()
+	}
+	_ = binary.Write(&buf, binary.BigEndian, expiresAt)
+
+	writeString(&buf, h.key)
+	_ = binary.Write(&buf, binary.BigEndian, uint32(len(h.tags)))
+	for _, tag := range h.tags {
+		writeString(&buf, tag)
+	}
+
+	return buf.Bytes()
+}
+
+// readHeader decodes the header at the beginning of r, leaving r at the start of the value.
+func readHeader(r *bufio.Reader) (*header, error) {
+	var m [4]byte
+	if _, err := io.ReadFull(r, m[:]); err != nil || m != magic {
+		return nil, errForeign
+	}
+
+	h := &header{}
+	var expiresAt int64
+	if err := binary.Read(r, binary.BigEndian, &expiresAt); err != nil {
+		return nil, errCorrupted
+	}
+	if expiresAt != 0 {
+		h.expiresAt = time.Unix(0, expiresAt)
+	}
+
+	var err error
+	if h.key, err = readString(r); err != nil {
+		return nil, err
+	}
+
+	var count uint32
+	if err := binary.Read(r, binary.BigEndian, &count); err != nil {
+		return nil, errCorrupted
+	}
+	for i := uint32(0); i < count; i++ {
+		tag, err := readString(r)
+		if err != nil {
+			return nil, err
+		}
+		h.tags = append(h.tags, tag)
+	}
+
+	return h, nil
+}
+
+// readFile reads the header and, if withValue is true, the value of an entry file.
+func readFile(path string, withValue bool) (*header, []byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	h, err := readHeader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s", err, path)
+	}
+	if !withValue {
+		return h, nil, nil
+	}
+
+	value, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return h, value, nil
+}
+
+// maxStringLen bounds the strings of a header, so a corrupted length cannot
+// trigger a huge allocation.
+const maxStringLen = 1 << 20
+
+func writeString(buf *bytes.Buffer, s string) {
+	_ = binary.Write(buf, binary.BigEndian, uint32(len(s)))
+	buf.WriteString(s)
+}
+
+func readString(r *bufio.Reader) (string, error) {
+	var n uint32
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil || n > maxStringLen {
+		return "", errCorrupted
+	}
+
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", errCorrupted
+	}
+
+	return string(b), nil
+}
@@ -0,0 +1,42 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package disk
+
+import (
+	"time"
+)
+
+// Option represents a disk store option function.
+type Option func(o *Options)
+
+// Options represents the options for disk store configuration.
+type Options struct {
+	// MaxBytes is the quota of the total size of the entry files. The least
+	// recently used entries are removed once it is exceeded. Zero means unbounded.
+	MaxBytes int64
+	// CleanupInterval is the period of the janitor removing expired entries.
+	// Expired entries are never returned, the janitor only reclaims their
+	// disk space. A zero or negative value disables the janitor.
+	CleanupInterval time.Duration
+}
+
+// WithMaxBytes sets the quota of the total size of the entry files.
+func WithMaxBytes(maxBytes int64) Option {
+	return func(o *Options) {
+		o.MaxBytes = maxBytes
+	}
+}
+
+// WithCleanupInterval sets the period of the janitor removing expired entries.
+func WithCleanupInterval(interval time.Duration) Option {
+	return func(o *Options) {
+		o.CleanupInterval = interval
+	}
+}
+
+// NewOptions instantiates a new Options with default values.
+func NewOptions() *Options {
+	return &Options{
+		CleanupInterval: 10 * time.Minute,
+	}
+}
@@ -4,8 +4,11 @@ package gocache
 
 import (
 	"context"
+	"strings"
 	"time"
 
+	gocache "github.com/patrickmn/go-cache"
+
 	"github.com/snail-plus/gopkg/cache/store"
 )
 
@@ -25,16 +28,84 @@ type GoCacheClientInterface interface {
 	Flush()
 }
 
+// itemsLister is implemented by the clients that can enumerate their items,
+// like *gocache.Cache.
+type itemsLister interface {
+	Items() map[string]gocache.Item
+}
+
+// evictionNotifier is implemented by the clients that report their evictions,
+// like *gocache.Cache.
+type evictionNotifier interface {
+	OnEvicted(f func(string, any))
+}
+
 // GoCacheStore is a store for GoCache (memory) library.
 type GoCacheStore struct {
 	client GoCacheClientInterface
+	opts   *Options
+	index  *store.Index
 }
 
 // NewGoCache creates a new store to GoCache (memory) library instance.
-func NewGoCache(client GoCacheClientInterface) *GoCacheStore {
-	return &GoCacheStore{
+//
+// When the client reports its evictions, like *gocache.Cache, the store
+// registers its own eviction callback to forget the tags of the expired items.
+// This replaces the callback previously set with (*gocache.Cache).OnEvicted,
+// which go-cache cannot return: pass it with WithOnEvicted instead, the store
+// calls it after its own. Otherwise, the tags of the expired items are only
+// forgotten once invalidated.
+//
+// When the client cannot enumerate its items, DelByPrefix and Snapshot only
+// see the tagged keys.
+func NewGoCache(client GoCacheClientInterface, options ...Option) *GoCacheStore {
+	opts := NewOptions()
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	s := &GoCacheStore{
 		client: client,
+		opts:   opts,
+		index:  store.NewIndex(),
+	}
+	if notifier, ok := client.(evictionNotifier); ok {
+		notifier.OnEvicted(s.onEvicted)
+	}
+	return s
+}
+
+// onEvicted forgets the tags of an item deleted or expired by the client,
+// unless the key has been written again since.
+func (s *GoCacheStore) onEvicted(key string, value any) {
+	if _, exists := s.client.Get(key); !exists {
+		s.index.Remove(key)
+	}
+	if s.opts.OnEvicted != nil {
+		s.opts.OnEvicted(key, value)
+	}
+}
+
+// items returns the unexpired items of the client, or of the tagged keys when
+// the client cannot enumerate them.
+func (s *GoCacheStore) items() map[string]gocache.Item {
+	if lister, ok := s.client.(itemsLister); ok {
+		return lister.Items()
 	}
+
+	items := make(map[string]gocache.Item)
+	for key := range s.index.Keys() {
+		value, expiresAt, exists := s.client.GetWithExpiration(key)
+		if !exists {
+			continue
+		}
+		item := gocache.Item{Object: value}
+		if !expiresAt.IsZero() {
+			item.Expiration = expiresAt.UnixNano()
+		}
+		items[key] = item
+	}
+	return items
 }
 
 // Get returns data stored from a given key.
@@ -60,24 +131,81 @@ func (s *GoCacheStore) GetWithTTL(_ context.Context, key any) (any, time.Duratio
 
 // Set defines data in GoCache memoey cache for given key identifier.
 func (s *GoCacheStore) Set(ctx context.Context, key any, value any) error {
-	s.client.Set(key.(string), value, 0)
-	return nil
+	return s.SetWithTags(ctx, key, value, 0)
 }
 
 func (s *GoCacheStore) SetWithTTL(ctx context.Context, key any, value any, ttl time.Duration) error {
+	return s.SetWithTags(ctx, key, value, ttl)
+}
+
+// SetWithTags defines data in GoCache memoey cache for given key identifier and attaches the tags to it.
+func (s *GoCacheStore) SetWithTags(_ context.Context, key any, value any, ttl time.Duration, tags ...string) error {
 	s.client.Set(key.(string), value, ttl)
+	s.index.Add(key.(string), tags...)
 	return nil
 }
 
 // Delete removes data in GoCache memoey cache for given key identifier.
 func (s *GoCacheStore) Del(_ context.Context, key any) error {
 	s.client.Delete(key.(string))
+	s.index.Remove(key.(string))
+	return nil
+}
+
+// GetMany returns data stored from the given keys.
+func (s *GoCacheStore) GetMany(_ context.Context, keys ...any) (map[any]any, error) {
+	values := make(map[any]any, len(keys))
+	for _, key := range keys {
+		if value, exists := s.client.Get(key.(string)); exists {
+			values[key] = value
+		}
+	}
+	return values, nil
+}
+
+// SetMany defines data in GoCache memoey cache for all given key identifiers.
+func (s *GoCacheStore) SetMany(ctx context.Context, items map[any]any, ttl time.Duration) error {
+	for key, value := range items {
+		if err := s.SetWithTags(ctx, key, value, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DelMany removes data in GoCache memoey cache for all given key identifiers.
+func (s *GoCacheStore) DelMany(ctx context.Context, keys ...any) error {
+	for _, key := range keys {
+		if err := s.Del(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InvalidateTags removes data attached to any of the given tags.
+func (s *GoCacheStore) InvalidateTags(_ context.Context, tags ...string) error {
+	for _, key := range s.index.Tagged(tags...) {
+		s.client.Delete(key)
+	}
+	return nil
+}
+
+// DelByPrefix removes data whose key starts with the given prefix.
+func (s *GoCacheStore) DelByPrefix(_ context.Context, prefix string) error {
+	for key := range s.items() {
+		if strings.HasPrefix(key, prefix) {
+			s.client.Delete(key)
+			s.index.Remove(key)
+		}
+	}
 	return nil
 }
 
 // Clear resets all data in the store.
 func (s *GoCacheStore) Clear(_ context.Context) error {
 	s.client.Flush()
+	s.index.Clear()
 	return nil
 }
 
@@ -0,0 +1,115 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package gocache
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	gocache "github.com/patrickmn/go-cache"
+
+	"github.com/snail-plus/gopkg/cache/store"
+)
+
+var _ store.Store = (*GoCacheStore)(nil)
+
+func TestGoCacheIndex(t *testing.T) {
+	ctx := context.Background()
+	s := NewGoCache(gocache.New(gocache.NoExpiration, 0))
+
+	if err := s.SetWithTags(ctx, "user:1", "a", time.Millisecond, "users"); err != nil {
+		t.Fatalf("SetWithTags() error = %v", err)
+	}
+	if err := s.Set(ctx, "user:2", "b"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if keys := s.index.Keys(); len(keys) != 1 {
+		t.Errorf("index holds %v, want only the tagged key", keys)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	s.client.(*gocache.Cache).DeleteExpired()
+	if keys := s.index.Keys(); len(keys) != 0 {
+		t.Errorf("index holds %v, want the expired key forgotten", keys)
+	}
+
+	if err := s.DelByPrefix(ctx, "user:"); err != nil {
+		t.Fatalf("DelByPrefix() error = %v", err)
+	}
+	if _, err := s.Get(ctx, "user:2"); err != store.ErrKeyNotFound {
+		t.Errorf("Get(user:2) error = %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestGoCacheRestore(t *testing.T) {
+	ctx := context.Background()
+	src := NewGoCache(gocache.New(gocache.NoExpiration, 0))
+	_ = src.SetWithTTL(ctx, "forever", "a", gocache.NoExpiration)
+	_ = src.SetWithTTL(ctx, "hour", "b", time.Hour)
+
+	var buf bytes.Buffer
+	if err := src.Snapshot(&buf); err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+
+	// The default expiration of the destination must not apply to the
+	// entries that never expire.
+	dst := NewGoCache(gocache.New(time.Millisecond, 0))
+	if err := dst.Restore(&buf); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ttl, err := dst.GetWithTTL(ctx, "hour"); err != nil || ttl <= 59*time.Minute {
+		t.Errorf("GetWithTTL(hour) = %v, %v, want the remaining hour", ttl, err)
+	}
+	if _, err := dst.Get(ctx, "forever"); err != nil {
+		t.Errorf("Get(forever) error = %v, want it never to expire", err)
+	}
+}
+
+// minimalClient implements only GoCacheClientInterface, without the optional
+// methods of *gocache.Cache.
+type minimalClient struct {
+	c *gocache.Cache
+}
+
+func (m minimalClient) Get(k string) (any, bool) { return m.c.Get(k) }
+func (m minimalClient) GetWithExpiration(k string) (any, time.Time, bool) {
+	return m.c.GetWithExpiration(k)
+}
+func (m minimalClient) Set(k string, x any, d time.Duration) { m.c.Set(k, x, d) }
+func (m minimalClient) Delete(k string)                      { m.c.Delete(k) }
+func (m minimalClient) Flush()                               { m.c.Flush() }
+
+func TestGoCacheOptionalClientMethods(t *testing.T) {
+	ctx := context.Background()
+
+	// The eviction callback of the caller is chained.
+	var evicted []string
+	s := NewGoCache(gocache.New(gocache.NoExpiration, 0), WithOnEvicted(func(key string, _ any) {
+		evicted = append(evicted, key)
+	}))
+	_ = s.Set(ctx, "a", "a")
+	_ = s.Del(ctx, "a")
+	if len(evicted) != 1 || evicted[0] != "a" {
+		t.Errorf("evicted = %v, want [a]", evicted)
+	}
+
+	// A client without Items and OnEvicted still works, DelByPrefix then only
+	// sees the tagged keys.
+	m := NewGoCache(minimalClient{c: gocache.New(gocache.NoExpiration, 0)})
+	_ = m.SetWithTags(ctx, "user:1", "a", 0, "users")
+	_ = m.Set(ctx, "user:2", "b")
+	if err := m.DelByPrefix(ctx, "user:"); err != nil {
+		t.Fatalf("DelByPrefix() error = %v", err)
+	}
+	if _, err := m.Get(ctx, "user:1"); err != store.ErrKeyNotFound {
+		t.Errorf("Get(user:1) error = %v, want ErrKeyNotFound", err)
+	}
+	if _, err := m.Get(ctx, "user:2"); err != nil {
+		t.Errorf("Get(user:2) error = %v, want the untagged key kept", err)
+	}
+}
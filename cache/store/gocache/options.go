@@ -0,0 +1,27 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package gocache
+
+// Option represents a go-cache store option function.
+type Option func(o *Options)
+
+// Options represents the options for go-cache store configuration.
+type Options struct {
+	// OnEvicted is called after an item is deleted or expired by the client.
+	// The store registers its own eviction callback on the clients that
+	// support it, replacing the one set on the client, so callers must pass
+	// their callback here instead.
+	OnEvicted func(key string, value any)
+}
+
+// WithOnEvicted sets the function called after an item is deleted or expired by the client.
+func WithOnEvicted(onEvicted func(key string, value any)) Option {
+	return func(o *Options) {
+		o.OnEvicted = onEvicted
+	}
+}
+
+// NewOptions instantiates a new Options with default values.
+func NewOptions() *Options {
+	return &Options{}
+}
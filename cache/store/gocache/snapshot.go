@@ -0,0 +1,58 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package gocache
+
+import (
+	"context"
+	"io"
+	"time"
+
+	gocache "github.com/patrickmn/go-cache"
+
+	"github.com/snail-plus/gopkg/cache/store"
+)
+
+var _ store.Snapshotter = (*GoCacheStore)(nil)
+
+// Snapshot writes the unexpired items of the store to w, only the tagged
+// ones when the client cannot enumerate its items.
+func (s *GoCacheStore) Snapshot(w io.Writer) error {
+	sw, err := store.NewSnapshotWriter(w)
+	if err != nil {
+		return err
+	}
+
+	tags := s.index.Keys()
+	for key, item := range s.items() {
+		e := &store.SnapshotEntry{Key: key, Value: item.Object, Tags: tags[key]}
+		if item.Expiration > 0 {
+			e.ExpiresAt = time.Unix(0, item.Expiration)
+		}
+		if err := sw.Write(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Restore adds the unexpired items of the snapshot read from r to the store,
+// with their remaining TTL. The items already in the store are kept.
+func (s *GoCacheStore) Restore(r io.Reader) error {
+	return store.ReadSnapshot(r, func(e *store.SnapshotEntry) error {
+		if _, exists := s.client.Get(e.Key); exists {
+			return nil
+		}
+
+		// go-cache reads a zero TTL as its default expiration, and a negative
+		// one as no expiration.
+		ttl := gocache.NoExpiration
+		if !e.ExpiresAt.IsZero() {
+			if ttl = e.TTL(time.Now()); ttl < 0 {
+				return nil
+			}
+		}
+
+		return s.SetWithTags(context.Background(), e.Key, e.Value, ttl, e.Tags...)
+	})
+}
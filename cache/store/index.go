@@ -0,0 +1,102 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package store
+
+import "sync"
+
+// Index keeps track of the tags attached to the keys of a store whose backend
+// cannot look keys up by tag. Only the keys written with tags are recorded, the
+// store must remove the keys its backend expires or evicts so that the index
+// does not outgrow the backend.
+type Index struct {
+	mu sync.Mutex
+	// keys maps every tagged key to the tags attached to it.
+	keys map[string][]string
+	// tags maps every tag to the keys attached to it.
+	tags map[string]map[string]struct{}
+}
+
+// NewIndex creates a new, empty index.
+func NewIndex() *Index {
+	return &Index{
+		keys: make(map[string][]string),
+		tags: make(map[string]map[string]struct{}),
+	}
+}
+
+// Add attaches the given tags to the key, replacing the tags previously
+// attached to it. A key added without tags is forgotten.
+func (i *Index) Add(key string, tags ...string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.remove(key)
+	for _, tag := range tags {
+		keys, ok := i.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			i.tags[tag] = keys
+		}
+		if _, ok := keys[key]; !ok {
+			keys[key] = struct{}{}
+			i.keys[key] = append(i.keys[key], tag)
+		}
+	}
+}
+
+// Remove forgets the key.
+func (i *Index) Remove(key string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.remove(key)
+}
+
+// Clear forgets all keys and tags.
+func (i *Index) Clear() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.keys = make(map[string][]string)
+	i.tags = make(map[string]map[string]struct{})
+}
+
+// Keys returns the tagged keys and the tags attached to them.
+func (i *Index) Keys() map[string][]string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	keys := make(map[string][]string, len(i.keys))
+	for key, tags := range i.keys {
+		keys[key] = append([]string(nil), tags...)
+	}
+
+	return keys
+}
+
+// Tagged forgets the keys attached to any of the given tags, and returns them.
+func (i *Index) Tagged(tags ...string) []string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	var keys []string
+	for _, tag := range tags {
+		for key := range i.tags[tag] {
+			keys = append(keys, key)
+			i.remove(key)
+		}
+	}
+
+	return keys
+}
+
+// remove forgets the key, i.mu must be held.
+func (i *Index) remove(key string) {
+	for _, tag := range i.keys[key] {
+		delete(i.tags[tag], key)
+		if len(i.tags[tag]) == 0 {
+			delete(i.tags, tag)
+		}
+	}
+	delete(i.keys, key)
+}
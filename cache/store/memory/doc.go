@@ -0,0 +1,5 @@
+// Copyright 2024 eve.  All rights reserved.
+
+// Package memory provides a size-bounded in-memory store with LRU, LFU or ARC
+// eviction, which does not depend on any external cache library.
+package memory // import "github.com/snail-plus/gopkg/cache/store/memory"
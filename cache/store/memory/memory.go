@@ -0,0 +1,366 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package memory
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snail-plus/gopkg/cache/store"
+)
+
+// MemoryType represents the storage type as a string value.
+const MemoryType = "memory"
+
+// ErrEntryTooLarge is returned when the cost of an entry exceeds Options.MaxBytes.
+var ErrEntryTooLarge = errors.New("entry cost exceeds the store capacity")
+
+// eviction is an entry that left the store, reported to Options.OnEvict.
+type eviction struct {
+	key    string
+	value  any
+	reason EvictionReason
+}
+
+// MemoryStore is a size-bounded in-memory store. Unlike ristretto, every
+// write is admitted: once Set returns, the value can be read back until it is
+// evicted to make room for later writes, expires or is deleted.
+type MemoryStore struct {
+	opts *Options
+
+	mu     sync.Mutex
+	items  map[string]*entry
+	policy policy
+	bytes  int64
+	index  *store.Index
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewMemory creates a new in-memory store. Close must be called to stop the
+// janitor once the store is no longer used.
+func NewMemory(options ...Option) *MemoryStore {
+	opts := NewOptions()
+	for _, opt := range options {
+		opt(opts)
+	}
+	if opts.Cost == nil {
+		opts.Cost = DefaultCost
+	}
+
+	s := &MemoryStore{
+		opts:  opts,
+		items: make(map[string]*entry),
+		index: store.NewIndex(),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	s.policy = newPolicy(opts.Policy, s.capacity)
+
+	if opts.CleanupInterval > 0 {
+		go s.janitor(opts.CleanupInterval)
+	} else {
+		close(s.done)
+	}
+
+	return s
+}
+
+// capacity returns the number of entries the store is expected to hold, s.mu must be held.
+func (s *MemoryStore) capacity() int {
+	if s.opts.MaxEntries > 0 {
+		return s.opts.MaxEntries
+	}
+	return max(1, len(s.items))
+}
+
+// Get returns data stored from a given key.
+func (s *MemoryStore) Get(_ context.Context, key any) (any, error) {
+	value, _, err := s.get(key.(string))
+	return value, err
+}
+
+// GetWithTTL returns data stored from a given key and its corresponding TTL,
+// zero meaning it does not expire.
+func (s *MemoryStore) GetWithTTL(_ context.Context, key any) (any, time.Duration, error) {
+	return s.get(key.(string))
+}
+
+// get returns the value and the remaining TTL of the key.
+func (s *MemoryStore) get(key string) (any, time.Duration, error) {
+	var evicted []eviction
+	defer func() { s.notify(evicted) }()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.items[key]
+	if !ok {
+		return nil, 0, store.ErrKeyNotFound
+	}
+
+	now := time.Now()
+	if e.expired(now) {
+		evicted = s.remove(e, EvictedExpired, evicted)
+		return nil, 0, store.ErrKeyNotFound
+	}
+
+	s.policy.access(e)
+
+	var ttl time.Duration
+	if !e.expiresAt.IsZero() {
+		ttl = e.expiresAt.Sub(now)
+	}
+	return e.value, ttl, nil
+}
+
+// Set defines data in memory for given key identifier.
+func (s *MemoryStore) Set(ctx context.Context, key any, value any) error {
+	return s.SetWithTags(ctx, key, value, 0)
+}
+
+// SetWithTTL defines data in memory for given key identifier with the given TTL.
+func (s *MemoryStore) SetWithTTL(ctx context.Context, key any, value any, ttl time.Duration) error {
+	return s.SetWithTags(ctx, key, value, ttl)
+}
+
+// SetWithTags defines data in memory for given key identifier and attaches the tags to it.
+func (s *MemoryStore) SetWithTags(_ context.Context, key any, value any, ttl time.Duration, tags ...string) error {
+	var evicted []eviction
+	defer func() { s.notify(evicted) }()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	evicted, err = s.set(key.(string), value, ttl, tags, evicted)
+	return err
+}
+
+// set writes the entry and evicts other entries until the store fits its
+// bounds, s.mu must be held.
+func (s *MemoryStore) set(key string, value any, ttl time.Duration, tags []string, evicted []eviction) ([]eviction, error) {
+	cost := s.opts.Cost(key, value)
+	if s.opts.MaxBytes > 0 && cost > s.opts.MaxBytes {
+		return evicted, ErrEntryTooLarge
+	}
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+
+	e, ok := s.items[key]
+	if ok {
+		s.bytes += cost - e.cost
+		e.value, e.cost, e.expiresAt = value, cost, expiresAt
+		s.policy.access(e)
+	} else {
+		e = &entry{key: key, value: value, cost: cost, expiresAt: expiresAt}
+		s.items[key] = e
+		s.bytes += cost
+		s.policy.add(e)
+	}
+	s.index.Add(key, tags...)
+
+	for s.full() {
+		victim := s.policy.evict(e)
+		if victim == nil {
+			break
+		}
+		evicted = s.drop(victim, EvictedCapacity, evicted)
+	}
+
+	return evicted, nil
+}
+
+// full reports whether the store exceeds its bounds, s.mu must be held.
+func (s *MemoryStore) full() bool {
+	return (s.opts.MaxEntries > 0 && len(s.items) > s.opts.MaxEntries) ||
+		(s.opts.MaxBytes > 0 && s.bytes > s.opts.MaxBytes)
+}
+
+// Del removes data in memory for given key identifier.
+func (s *MemoryStore) Del(_ context.Context, key any) error {
+	return s.del(key.(string))
+}
+
+// GetMany returns data stored from the given keys.
+func (s *MemoryStore) GetMany(_ context.Context, keys ...any) (map[any]any, error) {
+	values := make(map[any]any, len(keys))
+	for _, key := range keys {
+		if value, _, err := s.get(key.(string)); err == nil {
+			values[key] = value
+		}
+	}
+	return values, nil
+}
+
+// SetMany defines data in memory for all given key identifiers.
+func (s *MemoryStore) SetMany(_ context.Context, items map[any]any, ttl time.Duration) error {
+	var evicted []eviction
+	defer func() { s.notify(evicted) }()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, value := range items {
+		var err error
+		if evicted, err = s.set(key.(string), value, ttl, nil, evicted); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DelMany removes data in memory for all given key identifiers.
+func (s *MemoryStore) DelMany(_ context.Context, keys ...any) error {
+	strKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		strKeys = append(strKeys, key.(string))
+	}
+	return s.del(strKeys...)
+}
+
+// InvalidateTags removes data attached to any of the given tags.
+func (s *MemoryStore) InvalidateTags(_ context.Context, tags ...string) error {
+	return s.del(s.index.Tagged(tags...)...)
+}
+
+// DelByPrefix removes data whose key starts with the given prefix.
+func (s *MemoryStore) DelByPrefix(_ context.Context, prefix string) error {
+	var evicted []eviction
+	defer func() { s.notify(evicted) }()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, e := range s.items {
+		if strings.HasPrefix(key, prefix) {
+			evicted = s.remove(e, EvictedDeleted, evicted)
+		}
+	}
+	return nil
+}
+
+// del removes the given keys.
+func (s *MemoryStore) del(keys ...string) error {
+	var evicted []eviction
+	defer func() { s.notify(evicted) }()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		if e, ok := s.items[key]; ok {
+			evicted = s.remove(e, EvictedDeleted, evicted)
+		}
+	}
+	return nil
+}
+
+// Clear removes all data in the store, without calling Options.OnEvict.
+func (s *MemoryStore) Clear(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items = make(map[string]*entry)
+	s.policy = newPolicy(s.opts.Policy, s.capacity)
+	s.bytes = 0
+	s.index.Clear()
+	return nil
+}
+
+func (s *MemoryStore) Wait(_ context.Context) {
+}
+
+// Len returns the number of entries in the store, including the expired
+// entries not yet removed by the janitor.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.items)
+}
+
+// Bytes returns the total cost of the entries in the store.
+func (s *MemoryStore) Bytes() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.bytes
+}
+
+// Close stops the janitor. The store remains usable, but expired entries are
+// then only removed when they are read.
+func (s *MemoryStore) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+	})
+	<-s.done
+
+	return nil
+}
+
+// janitor periodically removes the expired entries until the store is closed.
+func (s *MemoryStore) janitor(interval time.Duration) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.deleteExpired()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// deleteExpired removes the expired entries.
+func (s *MemoryStore) deleteExpired() {
+	var evicted []eviction
+	defer func() { s.notify(evicted) }()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, e := range s.items {
+		if e.expired(now) {
+			evicted = s.remove(e, EvictedExpired, evicted)
+		}
+	}
+}
+
+// remove stops tracking the entry in the policy and drops it, s.mu must be held.
+func (s *MemoryStore) remove(e *entry, reason EvictionReason, evicted []eviction) []eviction {
+	s.policy.remove(e)
+	return s.drop(e, reason, evicted)
+}
+
+// drop deletes an entry no longer tracked by the policy, s.mu must be held.
+func (s *MemoryStore) drop(e *entry, reason EvictionReason, evicted []eviction) []eviction {
+	delete(s.items, e.key)
+	s.bytes -= e.cost
+	s.index.Remove(e.key)
+
+	if s.opts.OnEvict == nil {
+		return evicted
+	}
+	return append(evicted, eviction{key: e.key, value: e.value, reason: reason})
+}
+
+// notify reports the evicted entries to Options.OnEvict, s.mu must not be held.
+func (s *MemoryStore) notify(evicted []eviction) {
+	for _, ev := range evicted {
+		s.opts.OnEvict(ev.key, ev.value, ev.reason)
+	}
+}
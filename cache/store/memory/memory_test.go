@@ -0,0 +1,157 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/snail-plus/gopkg/cache/store"
+)
+
+var _ store.Store = (*MemoryStore)(nil)
+
+func TestMemoryEviction(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		policy Policy
+		// evicted is the key evicted by the last write.
+		evicted string
+	}{
+		{policy: LRU, evicted: "b"},
+		{policy: LFU, evicted: "a"},
+		{policy: ARC, evicted: "b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			var evicted []string
+			s := NewMemory(WithPolicy(tt.policy), WithMaxEntries(3), WithOnEvict(func(key string, _ any, reason EvictionReason) {
+				if reason == EvictedCapacity {
+					evicted = append(evicted, key)
+				}
+			}))
+			defer s.Close()
+
+			for _, key := range []string{"a", "b", "c"} {
+				if err := s.Set(ctx, key, key); err != nil {
+					t.Fatalf("Set(%q) error = %v", key, err)
+				}
+			}
+			// "b" is the least recently but the most frequently used.
+			for _, key := range []string{"b", "b", "a", "c"} {
+				_, _ = s.Get(ctx, key)
+			}
+
+			if err := s.Set(ctx, "d", "d"); err != nil {
+				t.Fatalf("Set(d) error = %v", err)
+			}
+			if len(evicted) != 1 || evicted[0] != tt.evicted {
+				t.Fatalf("evicted %v, want [%s]", evicted, tt.evicted)
+			}
+			if _, err := s.Get(ctx, "d"); err != nil {
+				t.Errorf("Get(d) error = %v, writes must always be admitted", err)
+			}
+			if s.Len() != 3 {
+				t.Errorf("Len() = %d, want 3", s.Len())
+			}
+		})
+	}
+}
+
+func TestMemoryMaxBytes(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory(WithMaxBytes(10), WithCost(func(_ string, value any) int64 {
+		return int64(len(value.([]byte)))
+	}))
+	defer s.Close()
+
+	if err := s.Set(ctx, "big", make([]byte, 11)); !errors.Is(err, ErrEntryTooLarge) {
+		t.Fatalf("Set() error = %v, want %v", err, ErrEntryTooLarge)
+	}
+
+	_ = s.Set(ctx, "a", make([]byte, 4))
+	_ = s.Set(ctx, "b", make([]byte, 4))
+	_ = s.Set(ctx, "c", make([]byte, 4))
+
+	if _, err := s.Get(ctx, "a"); !errors.Is(err, store.ErrKeyNotFound) {
+		t.Errorf("Get(a) error = %v, want it evicted", err)
+	}
+	if got := s.Bytes(); got != 8 {
+		t.Errorf("Bytes() = %d, want 8", got)
+	}
+}
+
+func TestMemoryTTL(t *testing.T) {
+	ctx := context.Background()
+	expired := make(chan string, 1)
+	s := NewMemory(WithCleanupInterval(5*time.Millisecond), WithOnEvict(func(key string, _ any, reason EvictionReason) {
+		if reason == EvictedExpired {
+			expired <- key
+		}
+	}))
+	defer s.Close()
+
+	_ = s.SetWithTTL(ctx, "key", "value", 20*time.Millisecond)
+	if _, ttl, err := s.GetWithTTL(ctx, "key"); err != nil || ttl <= 0 || ttl > 20*time.Millisecond {
+		t.Fatalf("GetWithTTL() = %v, %v", ttl, err)
+	}
+
+	select {
+	case key := <-expired:
+		if key != "key" {
+			t.Errorf("expired %q, want key", key)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expired entry was not removed by the janitor")
+	}
+	if s.Len() != 0 {
+		t.Errorf("Len() = %d, want 0", s.Len())
+	}
+}
+
+func TestMemorySnapshot(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory()
+	defer s.Close()
+
+	_ = s.SetWithTags(ctx, "a", "1", 0, "tag")
+	_ = s.SetWithTTL(ctx, "b", 2, time.Hour)
+	_ = s.SetWithTTL(ctx, "expired", "3", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	path := t.TempDir() + "/snapshot"
+	if err := store.SaveSnapshot(s, path); err != nil {
+		t.Fatalf("SaveSnapshot() error = %v", err)
+	}
+
+	restored := NewMemory()
+	defer restored.Close()
+	_ = restored.Set(ctx, "a", "newer")
+	if err := store.LoadSnapshot(restored, path); err != nil {
+		t.Fatalf("LoadSnapshot() error = %v", err)
+	}
+
+	if value, _ := restored.Get(ctx, "a"); value != "newer" {
+		t.Errorf("Get(a) = %v, want the newer value to be kept", value)
+	}
+	if value, ttl, err := restored.GetWithTTL(ctx, "b"); err != nil || value != 2 || ttl <= 0 || ttl > time.Hour {
+		t.Errorf("GetWithTTL(b) = %v, %v, %v, want 2 with its remaining TTL", value, ttl, err)
+	}
+	if _, err := restored.Get(ctx, "expired"); !errors.Is(err, store.ErrKeyNotFound) {
+		t.Errorf("Get(expired) error = %v, want %v", err, store.ErrKeyNotFound)
+	}
+
+	// Tags survive the restart.
+	restored2 := NewMemory()
+	defer restored2.Close()
+	if err := store.LoadSnapshot(restored2, path); err != nil {
+		t.Fatalf("LoadSnapshot() error = %v", err)
+	}
+	_ = restored2.InvalidateTags(ctx, "tag")
+	if _, err := restored2.Get(ctx, "a"); !errors.Is(err, store.ErrKeyNotFound) {
+		t.Errorf("Get(a) error = %v, want the tagged key to be invalidated", err)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package memory
+
+import (
+	"time"
+)
+
+// Policy is the algorithm used to choose the entries to evict when the store is full.
+type Policy string
+
+const (
+	// LRU evicts the least recently used entry.
+	LRU Policy = "lru"
+	// LFU evicts the least frequently used entry, the least recently used
+	// one among entries used as often.
+	LFU Policy = "lfu"
+	// ARC (Adaptive Replacement Cache) balances between recency and frequency
+	// based on the entries recently evicted.
+	ARC Policy = "arc"
+)
+
+// EvictionReason tells why an entry left the store.
+type EvictionReason int
+
+const (
+	// EvictedCapacity means the entry was evicted to make room for another one.
+	EvictedCapacity EvictionReason = iota
+	// EvictedExpired means the entry reached its TTL.
+	EvictedExpired
+	// EvictedDeleted means the entry was removed through the store.
+	EvictedDeleted
+)
+
+// String returns the name of the reason.
+func (r EvictionReason) String() string {
+	switch r {
+	case EvictedCapacity:
+		return "capacity"
+	case EvictedExpired:
+		return "expired"
+	case EvictedDeleted:
+		return "deleted"
+	default:
+		return "unknown"
+	}
+}
+
+// CostFunc returns the cost of a value, which counts against Options.MaxBytes.
+type CostFunc func(key string, value any) int64
+
+// Option represents a memory store option function.
+type Option func(o *Options)
+
+// Options represents the options for memory store configuration.
+type Options struct {
+	// Policy is the eviction policy, LRU by default.
+	Policy Policy
+	// MaxEntries is the maximum number of entries, zero means unbounded.
+	MaxEntries int
+	// MaxBytes is the maximum total cost of the entries, zero means unbounded.
+	MaxBytes int64
+	// Cost returns the cost of an entry, DefaultCost by default.
+	Cost CostFunc
+	// CleanupInterval is the period of the janitor removing expired entries.
+	// Expired entries are never returned, the janitor only reclaims their
+	// memory. A zero or negative value disables the janitor.
+	CleanupInterval time.Duration
+	// OnEvict is called after an entry left the store, outside of any lock.
+	// It is not called for entries replaced by a write or removed by Clear.
+	OnEvict func(key string, value any, reason EvictionReason)
+}
+
+// WithPolicy sets the eviction policy.
+func WithPolicy(policy Policy) Option {
+	return func(o *Options) {
+		o.Policy = policy
+	}
+}
+
+// WithMaxEntries sets the maximum number of entries.
+func WithMaxEntries(maxEntries int) Option {
+	return func(o *Options) {
+		o.MaxEntries = maxEntries
+	}
+}
+
+// WithMaxBytes sets the maximum total cost of the entries.
+func WithMaxBytes(maxBytes int64) Option {
+	return func(o *Options) {
+		o.MaxBytes = maxBytes
+	}
+}
+
+// WithCost sets the function computing the cost of an entry.
+func WithCost(cost CostFunc) Option {
+	return func(o *Options) {
+		o.Cost = cost
+	}
+}
+
+// WithCleanupInterval sets the period of the janitor removing expired entries.
+func WithCleanupInterval(interval time.Duration) Option {
+	return func(o *Options) {
+		o.CleanupInterval = interval
+	}
+}
+
+// WithOnEvict sets the function called after an entry left the store.
+func WithOnEvict(onEvict func(key string, value any, reason EvictionReason)) Option {
+	return func(o *Options) {
+		o.OnEvict = onEvict
+	}
+}
+
+// NewOptions instantiates a new Options with default values.
+func NewOptions() *Options {
+	return &Options{
+		Policy:          LRU,
+		Cost:            DefaultCost,
+		CleanupInterval: time.Minute,
+	}
+}
+
+// DefaultCost returns the length of the key plus the length of string and
+// byte slice values. Any other value costs one byte besides its key.
+func DefaultCost(key string, value any) int64 {
+	switch typed := value.(type) {
+	case []byte:
+		return int64(len(key) + len(typed))
+	case string:
+		return int64(len(key) + len(typed))
+	default:
+		return int64(len(key) + 1)
+	}
+}
@@ -0,0 +1,277 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package memory
+
+import (
+	"container/heap"
+	"container/list"
+	"time"
+)
+
+// entry is an item of the store, along with the bookkeeping of its policy.
+type entry struct {
+	key       string
+	value     any
+	cost      int64
+	expiresAt time.Time
+
+	// elem is the element of the entry in the LRU and ARC lists.
+	elem *list.Element
+	// freq, seq and index order the entry in the LFU heap.
+	freq  uint64
+	seq   uint64
+	index int
+	// frequent tells whether the entry is in the frequent list of ARC.
+	frequent bool
+}
+
+// expired reports whether the entry has reached its TTL at the given time.
+func (e *entry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// policy decides which entry to evict when the store is full. Policies are
+// not safe for concurrent use, the store serializes the calls.
+type policy interface {
+	// add tracks a new entry.
+	add(e *entry)
+	// access records a read or an overwrite of a tracked entry.
+	access(e *entry)
+	// remove stops tracking an entry that was deleted or expired.
+	remove(e *entry)
+	// evict stops tracking the entry to evict and returns it, or nil when
+	// there is no entry other than the protected one.
+	evict(protected *entry) *entry
+}
+
+// newPolicy creates the policy of the given kind. capacity returns the number
+// of entries the store is expected to hold.
+func newPolicy(kind Policy, capacity func() int) policy {
+	switch kind {
+	case LFU:
+		return &lfu{}
+	case ARC:
+		return newARC(capacity)
+	default:
+		return &lru{ll: list.New()}
+	}
+}
+
+// lru evicts the least recently used entry.
+type lru struct {
+	ll *list.List
+}
+
+func (p *lru) add(e *entry) {
+	e.elem = p.ll.PushFront(e)
+}
+
+func (p *lru) access(e *entry) {
+	p.ll.MoveToFront(e.elem)
+}
+
+func (p *lru) remove(e *entry) {
+	p.ll.Remove(e.elem)
+}
+
+func (p *lru) evict(protected *entry) *entry {
+	return evictBack(p.ll, protected)
+}
+
+// lfu evicts the least frequently used entry, the least recently used one
+// among entries used as often.
+type lfu struct {
+	h   lfuHeap
+	seq uint64
+}
+
+func (p *lfu) add(e *entry) {
+	p.seq++
+	e.freq, e.seq = 1, p.seq
+	heap.Push(&p.h, e)
+}
+
+func (p *lfu) access(e *entry) {
+	p.seq++
+	e.freq++
+	e.seq = p.seq
+	heap.Fix(&p.h, e.index)
+}
+
+func (p *lfu) remove(e *entry) {
+	heap.Remove(&p.h, e.index)
+}
+
+func (p *lfu) evict(protected *entry) *entry {
+	if p.h.Len() == 0 {
+		return nil
+	}
+
+	e := heap.Pop(&p.h).(*entry)
+	if e != protected {
+		return e
+	}
+
+	var victim *entry
+	if p.h.Len() > 0 {
+		victim = heap.Pop(&p.h).(*entry)
+	}
+	heap.Push(&p.h, e)
+	return victim
+}
+
+// lfuHeap is a min-heap of entries ordered by frequency, then by last access.
+type lfuHeap []*entry
+
+func (h lfuHeap) Len() int { return len(h) }
+
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].freq != h[j].freq {
+		return h[i].freq < h[j].freq
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap) Push(x any) {
+	e := x.(*entry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *lfuHeap) Pop() any {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return e
+}
+
+// arc implements the Adaptive Replacement Cache. Entries seen once live in
+// the recent list, entries seen again move to the frequent list. The keys of
+// evicted entries are remembered in ghost lists; a write to a ghost key grows
+// the target size of the list it was evicted from.
+type arc struct {
+	capacity func() int
+	// target is the target size of the recent list.
+	target                 int
+	recent, frequent       *list.List
+	recentGhost, freqGhost *list.List
+	ghosts                 map[string]ghost
+}
+
+// ghost is the element of an evicted key in one of the ghost lists.
+type ghost struct {
+	elem   *list.Element
+	recent bool
+}
+
+func newARC(capacity func() int) *arc {
+	return &arc{
+		capacity:    capacity,
+		recent:      list.New(),
+		frequent:    list.New(),
+		recentGhost: list.New(),
+		freqGhost:   list.New(),
+		ghosts:      make(map[string]ghost),
+	}
+}
+
+func (p *arc) add(e *entry) {
+	g, ok := p.ghosts[e.key]
+	if !ok {
+		e.frequent = false
+		e.elem = p.recent.PushFront(e)
+		return
+	}
+
+	c := p.capacity()
+	if g.recent {
+		p.target = min(c, p.target+max(1, p.freqGhost.Len()/max(1, p.recentGhost.Len())))
+		p.recentGhost.Remove(g.elem)
+	} else {
+		p.target = max(0, p.target-max(1, p.recentGhost.Len()/max(1, p.freqGhost.Len())))
+		p.freqGhost.Remove(g.elem)
+	}
+	delete(p.ghosts, e.key)
+
+	e.frequent = true
+	e.elem = p.frequent.PushFront(e)
+}
+
+func (p *arc) access(e *entry) {
+	if e.frequent {
+		p.frequent.MoveToFront(e.elem)
+		return
+	}
+
+	p.recent.Remove(e.elem)
+	e.frequent = true
+	e.elem = p.frequent.PushFront(e)
+}
+
+func (p *arc) remove(e *entry) {
+	p.list(e).Remove(e.elem)
+}
+
+func (p *arc) evict(protected *entry) *entry {
+	lists := []*list.List{p.frequent, p.recent}
+	if p.recent.Len() > 0 && (p.recent.Len() > p.target || p.frequent.Len() == 0) {
+		lists = []*list.List{p.recent, p.frequent}
+	}
+
+	for _, l := range lists {
+		e := evictBack(l, protected)
+		if e == nil {
+			continue
+		}
+
+		ghosts := p.freqGhost
+		if l == p.recent {
+			ghosts = p.recentGhost
+		}
+		p.ghosts[e.key] = ghost{elem: ghosts.PushFront(e.key), recent: l == p.recent}
+		p.trimGhosts()
+		return e
+	}
+
+	return nil
+}
+
+// list returns the list holding the entry.
+func (p *arc) list(e *entry) *list.List {
+	if e.frequent {
+		return p.frequent
+	}
+	return p.recent
+}
+
+// trimGhosts bounds each ghost list to the capacity of the store.
+func (p *arc) trimGhosts() {
+	c := p.capacity()
+	for _, l := range []*list.List{p.recentGhost, p.freqGhost} {
+		for l.Len() > c {
+			delete(p.ghosts, l.Remove(l.Back()).(string))
+		}
+	}
+}
+
+// evictBack removes and returns the entry at the back of the list, skipping
+// the protected entry.
+func evictBack(l *list.List, protected *entry) *entry {
+	elem := l.Back()
+	if elem != nil && elem.Value.(*entry) == protected {
+		elem = elem.Prev()
+	}
+	if elem == nil {
+		return nil
+	}
+
+	return l.Remove(elem).(*entry)
+}
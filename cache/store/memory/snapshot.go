@@ -0,0 +1,70 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package memory
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/snail-plus/gopkg/cache/store"
+)
+
+var _ store.Snapshotter = (*MemoryStore)(nil)
+
+// Snapshot writes the unexpired entries of the store to w. The entries are
+// copied under the lock and encoded after it is released, so the store is
+// only blocked for the copy.
+func (s *MemoryStore) Snapshot(w io.Writer) error {
+	tags := s.index.Keys()
+
+	s.mu.Lock()
+	now := time.Now()
+	entries := make([]*store.SnapshotEntry, 0, len(s.items))
+	for key, e := range s.items {
+		if e.expired(now) {
+			continue
+		}
+		entries = append(entries, &store.SnapshotEntry{
+			Key:       key,
+			Value:     e.value,
+			ExpiresAt: e.expiresAt,
+			Tags:      tags[key],
+		})
+	}
+	s.mu.Unlock()
+
+	sw, err := store.NewSnapshotWriter(w)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := sw.Write(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Restore adds the unexpired entries of the snapshot read from r to the
+// store, with their remaining TTL. The entries already in the store are
+// newer than the snapshot, they are kept.
+func (s *MemoryStore) Restore(r io.Reader) error {
+	return store.ReadSnapshot(r, func(e *store.SnapshotEntry) error {
+		s.mu.Lock()
+		_, exists := s.items[e.Key]
+		s.mu.Unlock()
+		if exists {
+			return nil
+		}
+
+		// The entry may have expired since the snapshot was read.
+		ttl := e.TTL(time.Now())
+		if ttl < 0 {
+			return nil
+		}
+
+		return s.SetWithTags(context.Background(), e.Key, e.Value, ttl, e.Tags...)
+	})
+}
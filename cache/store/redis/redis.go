@@ -5,6 +5,8 @@ package redis
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -15,23 +17,89 @@ import (
 const (
 	// RedisType represents the storage type as a string value.
 	RedisType = "redis"
+	// RedisTagPattern represents the tag pattern to be used as a key in specified storage.
+	RedisTagPattern = "gocache_tag_%s"
+
+	// tagKeyExpiry is the minimum TTL of the sets holding the keys of a tag,
+	// when all of them expire.
+	tagKeyExpiry = 720 * time.Hour
+	// scanCount is the number of keys requested per SCAN iteration, and
+	// the number of keys unlinked per round trip.
+	scanCount = 1000
 )
 
+// Option represents a redis store option function.
+type Option func(o *Options)
+
+// Options represents the options for redis store configuration.
+type Options struct {
+	// Namespace is prepended to every key written by the store. Clear and
+	// DelByPrefix only ever touch keys under the namespace, so several
+	// stores and services can share a Redis database.
+	Namespace string
+}
+
+// WithNamespace sets the namespace prepended to every key.
+func WithNamespace(namespace string) Option {
+	return func(o *Options) {
+		o.Namespace = namespace
+	}
+}
+
+// tagScript adds a key to the set of a tag, keeping the set at least as long
+// as the key. The set never expires once it holds a key without expiration
+// (ARGV[2] is 0), and its expiry is only ever extended otherwise.
+var tagScript = redis.NewScript(`
+local existed = redis.call('EXISTS', KEYS[1])
+redis.call('SADD', KEYS[1], ARGV[1])
+local expiry = tonumber(ARGV[2])
+if expiry <= 0 then
+	redis.call('PERSIST', KEYS[1])
+	return 0
+end
+local pttl = redis.call('PTTL', KEYS[1])
+if existed == 1 and pttl < 0 then
+	return 0
+end
+if pttl < expiry then
+	redis.call('PEXPIRE', KEYS[1], expiry)
+end
+return 0
+`)
+
 // RedisStore is a store for Redis.
 type RedisStore struct {
-	client *redis.Client
+	client    redis.UniversalClient
+	namespace string
 }
 
-// NewRedis creates a new store to Redis instance(s).
-func NewRedis(client *redis.Client) *RedisStore {
+// NewRedis creates a new store to Redis instance(s). The client may be a
+// standalone, sentinel (failover) or cluster client.
+func NewRedis(client redis.UniversalClient, options ...Option) *RedisStore {
+	opts := &Options{}
+	for _, opt := range options {
+		opt(opts)
+	}
+
 	return &RedisStore{
-		client: client,
+		client:    client,
+		namespace: opts.Namespace,
 	}
 }
 
+// key returns the Redis key of the given cache key.
+func (s *RedisStore) key(key any) string {
+	return s.namespace + key.(string)
+}
+
+// tagKey returns the Redis key of the set holding the keys of the given tag.
+func (s *RedisStore) tagKey(tag string) string {
+	return s.namespace + fmt.Sprintf(RedisTagPattern, tag)
+}
+
 // Get returns data stored from a given key.
 func (s *RedisStore) Get(ctx context.Context, key any) (any, error) {
-	obj, err := s.client.Get(ctx, key.(string)).Result()
+	obj, err := s.client.Get(ctx, s.key(key)).Result()
 	if errors.Is(err, redis.Nil) {
 		return nil, store.ErrKeyNotFound
 	}
@@ -39,8 +107,15 @@ func (s *RedisStore) Get(ctx context.Context, key any) (any, error) {
 }
 
 // GetWithTTL returns data stored from a given key and its corresponding TTL.
+// Both are fetched in a single round trip.
 func (s *RedisStore) GetWithTTL(ctx context.Context, key any) (any, time.Duration, error) {
-	obj, err := s.client.Get(ctx, key.(string)).Result()
+	var get *redis.StringCmd
+	var pttl *redis.DurationCmd
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, s.key(key))
+		pttl = pipe.PTTL(ctx, s.key(key))
+		return nil
+	})
 	if errors.Is(err, redis.Nil) {
 		return nil, 0, store.ErrKeyNotFound
 	}
@@ -48,17 +123,18 @@ func (s *RedisStore) GetWithTTL(ctx context.Context, key any) (any, time.Duratio
 		return nil, 0, err
 	}
 
-	ttl, err := s.client.TTL(ctx, key.(string)).Result()
-	if err != nil {
-		return nil, 0, err
+	// Keys without an expiration report a negative TTL.
+	ttl := pttl.Val()
+	if ttl < 0 {
+		ttl = 0
 	}
 
-	return obj, ttl, err
+	return get.Val(), ttl, nil
 }
 
 // Set defines data in Redis for given key identifier.
 func (s *RedisStore) Set(ctx context.Context, key any, value any) error {
-	err := s.client.Set(ctx, key.(string), value, 0).Err()
+	err := s.client.Set(ctx, s.key(key), value, 0).Err()
 	if err != nil {
 		return err
 	}
@@ -68,7 +144,7 @@ func (s *RedisStore) Set(ctx context.Context, key any, value any) error {
 
 // Set defines data in Redis for given key identifier.
 func (s *RedisStore) SetWithTTL(ctx context.Context, key any, value any, ttl time.Duration) error {
-	err := s.client.Set(ctx, key.(string), value, ttl).Err()
+	err := s.client.Set(ctx, s.key(key), value, ttl).Err()
 	if err != nil {
 		return err
 	}
@@ -76,20 +152,197 @@ func (s *RedisStore) SetWithTTL(ctx context.Context, key any, value any, ttl tim
 	return nil
 }
 
+// SetWithTags defines data in Redis for given key identifier and adds the key
+// to the set of every given tag.
+func (s *RedisStore) SetWithTags(ctx context.Context, key any, value any, ttl time.Duration, tags ...string) error {
+	if len(tags) == 0 {
+		return s.SetWithTTL(ctx, key, value, ttl)
+	}
+
+	// Tag sets must outlive the keys they hold: a key without expiration
+	// makes its tag sets persistent. The commands are not sent in a
+	// transaction since the key and the tag sets may live on different
+	// cluster slots.
+	var expiry time.Duration
+	if ttl > 0 {
+		expiry = max(ttl, tagKeyExpiry)
+	}
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.key(key), value, ttl)
+		for _, tag := range tags {
+			tagScript.Eval(ctx, pipe, []string{s.tagKey(tag)}, s.key(key), expiry.Milliseconds())
+		}
+		return nil
+	})
+
+	return err
+}
+
+// InvalidateTags removes data attached to any of the given tags.
+func (s *RedisStore) InvalidateTags(ctx context.Context, tags ...string) error {
+	for _, tag := range tags {
+		keys, err := s.client.SMembers(ctx, s.tagKey(tag)).Result()
+		if err != nil {
+			return err
+		}
+
+		if err := s.unlink(ctx, append(keys, s.tagKey(tag))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DelByPrefix removes data whose key starts with the given prefix.
+func (s *RedisStore) DelByPrefix(ctx context.Context, prefix string) error {
+	return s.unlinkMatching(ctx, escapePattern(s.namespace+prefix)+"*")
+}
+
 // Del removes data from Redis for given key identifier.
 func (s *RedisStore) Del(ctx context.Context, key any) error {
-	_, err := s.client.Del(ctx, key.(string)).Result()
+	_, err := s.client.Del(ctx, s.key(key)).Result()
+	return err
+}
+
+// GetMany returns data stored from the given keys in a single round trip. A
+// cluster client pipelines one GET per key instead of a MGET, since the keys
+// may live on different slots.
+func (s *RedisStore) GetMany(ctx context.Context, keys ...any) (map[any]any, error) {
+	values := make(map[any]any, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+
+	if _, ok := s.client.(*redis.ClusterClient); ok {
+		cmds := make([]*redis.StringCmd, len(keys))
+		_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, key := range keys {
+				cmds[i] = pipe.Get(ctx, s.key(key))
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+
+		for i, cmd := range cmds {
+			if cmd.Err() == nil {
+				values[keys[i]] = cmd.Val()
+			}
+		}
+		return values, nil
+	}
+
+	redisKeys := make([]string, len(keys))
+	for i, key := range keys {
+		redisKeys[i] = s.key(key)
+	}
+
+	results, err := s.client.MGet(ctx, redisKeys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, result := range results {
+		// Missing keys are reported as nil.
+		if result != nil {
+			values[keys[i]] = result
+		}
+	}
+	return values, nil
+}
+
+// SetMany defines data in Redis for all given key identifiers in a single round trip.
+func (s *RedisStore) SetMany(ctx context.Context, items map[any]any, ttl time.Duration) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for key, value := range items {
+			pipe.Set(ctx, s.key(key), value, ttl)
+		}
+		return nil
+	})
+
 	return err
 }
 
-// Clear resets all data in the store.
+// DelMany removes data from Redis for all given key identifiers in a single round trip.
+func (s *RedisStore) DelMany(ctx context.Context, keys ...any) error {
+	redisKeys := make([]string, len(keys))
+	for i, key := range keys {
+		redisKeys[i] = s.key(key)
+	}
+
+	return s.unlink(ctx, redisKeys)
+}
+
+// Clear removes all data under the store namespace. Keys are scanned and
+// unlinked in batches, so the server is never blocked. Without a namespace
+// this removes every key of the selected database.
 func (s *RedisStore) Clear(ctx context.Context) error {
-	if err := s.client.FlushAll(ctx).Err(); err != nil {
+	return s.unlinkMatching(ctx, escapePattern(s.namespace)+"*")
+}
+
+func (s *RedisStore) Wait(ctx context.Context) {
+}
+
+// unlinkMatching unlinks the keys matching the given SCAN pattern in batches.
+// A cluster is scanned master by master, since SCAN only covers one node.
+func (s *RedisStore) unlinkMatching(ctx context.Context, pattern string) error {
+	if cluster, ok := s.client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, master *redis.Client) error {
+			return s.unlinkScanned(ctx, master, pattern)
+		})
+	}
+
+	return s.unlinkScanned(ctx, s.client, pattern)
+}
+
+// unlinkScanned unlinks the keys of a single node matching the given SCAN pattern.
+func (s *RedisStore) unlinkScanned(ctx context.Context, node redis.Cmdable, pattern string) error {
+	iter := node.Scan(ctx, 0, pattern, scanCount).Iterator()
+
+	keys := make([]string, 0, scanCount)
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) == scanCount {
+			if err := s.unlink(ctx, keys); err != nil {
+				return err
+			}
+			keys = keys[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
 		return err
 	}
 
-	return nil
+	return s.unlink(ctx, keys)
 }
 
-func (s *RedisStore) Wait(ctx context.Context) {
+// unlink removes the given keys, reclaiming their memory in the background.
+// Keys are unlinked one per command in a single pipeline, so that keys of
+// different cluster slots can be removed together.
+func (s *RedisStore) unlink(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Unlink(ctx, key)
+		}
+		return nil
+	})
+
+	return err
+}
+
+var patternEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// escapePattern escapes the glob characters of s for use in a SCAN pattern.
+func escapePattern(s string) string {
+	return patternEscaper.Replace(s)
 }
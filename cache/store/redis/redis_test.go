@@ -0,0 +1,133 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/snail-plus/gopkg/cache/store"
+)
+
+var _ store.Store = (*RedisStore)(nil)
+
+func newTestStore(t *testing.T, options ...Option) (*RedisStore, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	return NewRedis(client, options...), mr
+}
+
+func TestRedisTags(t *testing.T) {
+	ctx := context.Background()
+	s, mr := newTestStore(t, WithNamespace("app:"))
+
+	if err := s.SetWithTags(ctx, "a", "a", 0, "users"); err != nil {
+		t.Fatalf("SetWithTags(a) error = %v", err)
+	}
+	if ttl := mr.TTL("app:gocache_tag_users"); ttl != 0 {
+		t.Errorf("tag TTL = %v, want no expiration for a key without TTL", ttl)
+	}
+
+	// An expiring key must not make the tag set of a persistent key expire.
+	if err := s.SetWithTags(ctx, "b", "b", time.Minute, "users"); err != nil {
+		t.Fatalf("SetWithTags(b) error = %v", err)
+	}
+	if ttl := mr.TTL("app:gocache_tag_users"); ttl != 0 {
+		t.Errorf("tag TTL = %v, want no expiration", ttl)
+	}
+
+	if err := s.SetWithTags(ctx, "c", "c", time.Minute, "orders"); err != nil {
+		t.Fatalf("SetWithTags(c) error = %v", err)
+	}
+	if ttl := mr.TTL("app:gocache_tag_orders"); ttl != tagKeyExpiry {
+		t.Errorf("tag TTL = %v, want %v", ttl, tagKeyExpiry)
+	}
+	// The expiry of a tag set is never shortened.
+	if err := s.SetWithTags(ctx, "d", "d", 1000*time.Hour, "orders"); err != nil {
+		t.Fatalf("SetWithTags(d) error = %v", err)
+	}
+	if err := s.SetWithTags(ctx, "e", "e", time.Minute, "orders"); err != nil {
+		t.Fatalf("SetWithTags(e) error = %v", err)
+	}
+	if ttl := mr.TTL("app:gocache_tag_orders"); ttl != 1000*time.Hour {
+		t.Errorf("tag TTL = %v, want %v", ttl, 1000*time.Hour)
+	}
+
+	if err := s.InvalidateTags(ctx, "users"); err != nil {
+		t.Fatalf("InvalidateTags() error = %v", err)
+	}
+	for _, key := range []string{"a", "b"} {
+		if _, err := s.Get(ctx, key); !errors.Is(err, store.ErrKeyNotFound) {
+			t.Errorf("Get(%q) error = %v, want ErrKeyNotFound", key, err)
+		}
+	}
+	if mr.Exists("app:gocache_tag_users") {
+		t.Error("tag set still exists after InvalidateTags")
+	}
+	if _, err := s.Get(ctx, "c"); err != nil {
+		t.Errorf("Get(c) error = %v, want the key of another tag kept", err)
+	}
+}
+
+func TestRedisDelByPrefix(t *testing.T) {
+	ctx := context.Background()
+	s, mr := newTestStore(t, WithNamespace("app:"))
+
+	for _, key := range []string{"user:1", "user:2", "user*", "order:1"} {
+		if err := s.Set(ctx, key, key); err != nil {
+			t.Fatalf("Set(%q) error = %v", key, err)
+		}
+	}
+	_ = mr.Set("user:3", "foreign")
+
+	if err := s.DelByPrefix(ctx, "user:"); err != nil {
+		t.Fatalf("DelByPrefix() error = %v", err)
+	}
+
+	for key, kept := range map[string]bool{"user:1": false, "user:2": false, "user*": true, "order:1": true} {
+		_, err := s.Get(ctx, key)
+		if kept && err != nil {
+			t.Errorf("Get(%q) error = %v, want the key kept", key, err)
+		}
+		if !kept && !errors.Is(err, store.ErrKeyNotFound) {
+			t.Errorf("Get(%q) error = %v, want ErrKeyNotFound", key, err)
+		}
+	}
+	if !mr.Exists("user:3") {
+		t.Error("DelByPrefix removed a key outside the namespace")
+	}
+}
+
+func TestRedisClear(t *testing.T) {
+	ctx := context.Background()
+	s, mr := newTestStore(t, WithNamespace("app:"))
+
+	// Enough keys to unlink several batches.
+	items := make(map[any]any, scanCount+10)
+	for i := range scanCount + 10 {
+		items[fmt.Sprintf("key:%d", i)] = i
+	}
+	if err := s.SetMany(ctx, items, 0); err != nil {
+		t.Fatalf("SetMany() error = %v", err)
+	}
+	_ = mr.Set("other:key", "foreign")
+
+	if err := s.Clear(ctx); err != nil {
+		t.Fatalf("Clear() error = %v", err)
+	}
+
+	keys := mr.Keys()
+	if len(keys) != 1 || keys[0] != "other:key" {
+		t.Errorf("keys after Clear = %v, want [other:key]", keys)
+	}
+}
@@ -5,8 +5,13 @@ package ristretto
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/dgraph-io/ristretto"
+	"github.com/dgraph-io/ristretto/z"
+
 	"github.com/snail-plus/gopkg/cache/store"
 )
 
@@ -20,6 +25,7 @@ const (
 // RistrettoClientInterface represents a dgraph-io/ristretto client.
 type RistrettoClientInterface interface {
 	Get(key any) (any, bool)
+	GetTTL(key any) (time.Duration, bool)
 	Set(key, value any, cost int64) bool
 	SetWithTTL(key, value any, cost int64, ttl time.Duration) bool
 	Del(key any)
@@ -27,15 +33,81 @@ type RistrettoClientInterface interface {
 	Wait()
 }
 
+// hashedKey identifies a key the way ristretto does.
+type hashedKey struct {
+	key      uint64
+	conflict uint64
+}
+
 // RistrettoStore is a store for Ristretto (memory) library.
 type RistrettoStore struct {
 	client RistrettoClientInterface
+	index  *store.Index
+
+	// keyToHash hashes the keys like the client does, to find the keys of
+	// the items it evicts.
+	keyToHash func(key any) (uint64, uint64)
+	mu        sync.Mutex
+	// keys maps the hash of every string key held by the client to the key,
+	// it is nil when the store is not notified of the evictions.
+	keys map[hashedKey]string
 }
 
 // NewRistretto creates a new store to Ristretto (memory) library instance.
+// Since the store is not notified of the items evicted by the client, it only
+// keeps track of the tagged keys: DelByPrefix only removes tagged keys, and
+// the tagged keys evicted by the client are only forgotten once invalidated.
+// Use NewRistrettoWithConfig to have the store track every key.
 func NewRistretto(client RistrettoClientInterface) *RistrettoStore {
 	return &RistrettoStore{
 		client: client,
+		index:  store.NewIndex(),
+	}
+}
+
+// NewRistrettoWithConfig creates a new Ristretto instance from the config and
+// a store to it. The store hooks the eviction callbacks of the config to
+// forget the keys evicted, expired or rejected by ristretto, the callbacks
+// already set are still called.
+func NewRistrettoWithConfig(cfg *ristretto.Config) (*RistrettoStore, error) {
+	s := &RistrettoStore{
+		index:     store.NewIndex(),
+		keyToHash: cfg.KeyToHash,
+		keys:      make(map[hashedKey]string),
+	}
+	if s.keyToHash == nil {
+		s.keyToHash = z.KeyToHash
+	}
+
+	hooked := *cfg
+	hooked.OnEvict = s.hook(cfg.OnEvict)
+	hooked.OnReject = s.hook(cfg.OnReject)
+
+	client, err := ristretto.NewCache(&hooked)
+	if err != nil {
+		return nil, err
+	}
+
+	s.client = client
+	return s, nil
+}
+
+// hook returns an eviction callback forgetting the key of the item before
+// calling next.
+func (s *RistrettoStore) hook(next func(item *ristretto.Item)) func(item *ristretto.Item) {
+	return func(item *ristretto.Item) {
+		s.mu.Lock()
+		h := hashedKey{key: item.Key, conflict: item.Conflict}
+		key, ok := s.keys[h]
+		delete(s.keys, h)
+		s.mu.Unlock()
+
+		if ok {
+			s.index.Remove(key)
+		}
+		if next != nil {
+			next(item)
+		}
 	}
 }
 
@@ -59,7 +131,9 @@ func (s *RistrettoStore) GetWithTTL(ctx context.Context, key any) (any, time.Dur
 
 // Set defines data in Ristretto memory cache for given key identifier.
 func (s *RistrettoStore) Set(_ context.Context, key any, value any) error {
+	s.indexKey(key)
 	if set := s.client.Set(key, value, 0); !set {
+		s.unindexKey(key)
 		return fmt.Errorf("an error has occurred while setting value '%v' on key '%v'", value, key)
 	}
 
@@ -67,22 +141,148 @@ func (s *RistrettoStore) Set(_ context.Context, key any, value any) error {
 }
 
 func (s *RistrettoStore) SetWithTTL(ctx context.Context, key any, value any, ttl time.Duration) error {
+	return s.SetWithTags(ctx, key, value, ttl)
+}
+
+// SetWithTags defines data in Ristretto memory cache for given key identifier and attaches the tags to it.
+func (s *RistrettoStore) SetWithTags(_ context.Context, key any, value any, ttl time.Duration, tags ...string) error {
+	s.indexKey(key, tags...)
 	if set := s.client.SetWithTTL(key, value, 0, ttl); !set {
+		s.unindexKey(key)
 		return fmt.Errorf("an error has occurred while setting value '%v' on key '%v'", value, key)
 	}
 
 	return nil
 }
 
+// indexKey records the key and its tags. Only string keys can be looked up by
+// tag or prefix. The key is recorded before being written, so that the
+// eviction callbacks, which may run as soon as it is written, find it.
+func (s *RistrettoStore) indexKey(key any, tags ...string) {
+	k, ok := key.(string)
+	if !ok {
+		return
+	}
+
+	if s.keys != nil {
+		h, conflict := s.keyToHash(k)
+		s.mu.Lock()
+		s.keys[hashedKey{key: h, conflict: conflict}] = k
+		s.mu.Unlock()
+	}
+	s.index.Add(k, tags...)
+}
+
+// unindexKey forgets the key whose write failed, unless it is still held by
+// the client.
+func (s *RistrettoStore) unindexKey(key any) {
+	if k, ok := key.(string); ok {
+		if _, exists := s.client.Get(k); !exists {
+			s.forget(k)
+		}
+	}
+}
+
+// forget forgets the key and its tags.
+func (s *RistrettoStore) forget(key string) {
+	if s.keys != nil {
+		h, conflict := s.keyToHash(key)
+		s.mu.Lock()
+		delete(s.keys, hashedKey{key: h, conflict: conflict})
+		s.mu.Unlock()
+	}
+	s.index.Remove(key)
+}
+
 // Delete removes data in Ristretto memory cache for given key identifier.
 func (s *RistrettoStore) Del(_ context.Context, key any) error {
 	s.client.Del(key)
+	if k, ok := key.(string); ok {
+		s.forget(k)
+	}
+	return nil
+}
+
+// GetMany returns data stored from the given keys.
+func (s *RistrettoStore) GetMany(_ context.Context, keys ...any) (map[any]any, error) {
+	values := make(map[any]any, len(keys))
+	for _, key := range keys {
+		if value, exists := s.client.Get(key); exists {
+			values[key] = value
+		}
+	}
+	return values, nil
+}
+
+// SetMany defines data in Ristretto memory cache for all given key identifiers.
+func (s *RistrettoStore) SetMany(ctx context.Context, items map[any]any, ttl time.Duration) error {
+	for key, value := range items {
+		if err := s.SetWithTags(ctx, key, value, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DelMany removes data in Ristretto memory cache for all given key identifiers.
+func (s *RistrettoStore) DelMany(ctx context.Context, keys ...any) error {
+	for _, key := range keys {
+		if err := s.Del(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InvalidateTags removes data attached to any of the given tags.
+func (s *RistrettoStore) InvalidateTags(_ context.Context, tags ...string) error {
+	for _, key := range s.index.Tagged(tags...) {
+		s.client.Del(key)
+	}
+	return nil
+}
+
+// DelByPrefix removes data whose key starts with the given prefix.
+func (s *RistrettoStore) DelByPrefix(_ context.Context, prefix string) error {
+	for _, key := range s.trackedKeys() {
+		if strings.HasPrefix(key, prefix) {
+			s.client.Del(key)
+			s.forget(key)
+		}
+	}
 	return nil
 }
 
+// trackedKeys returns the keys known to the store.
+func (s *RistrettoStore) trackedKeys() []string {
+	if s.keys == nil {
+		tagged := s.index.Keys()
+		keys := make([]string, 0, len(tagged))
+		for key := range tagged {
+			keys = append(keys, key)
+		}
+		return keys
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
 // Clear resets all data in the store.
 func (s *RistrettoStore) Clear(_ context.Context) error {
 	s.client.Clear()
+	if s.keys != nil {
+		s.mu.Lock()
+		s.keys = make(map[hashedKey]string)
+		s.mu.Unlock()
+	}
+	s.index.Clear()
 	return nil
 }
 
@@ -0,0 +1,106 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package ristretto
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/ristretto"
+
+	"github.com/snail-plus/gopkg/cache/store"
+)
+
+var _ store.Store = (*RistrettoStore)(nil)
+
+func TestRistrettoForgetsEvictedKeys(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewRistrettoWithConfig(&ristretto.Config{
+		NumCounters:        100,
+		MaxCost:            2,
+		BufferItems:        64,
+		Cost:               func(any) int64 { return 1 },
+		IgnoreInternalCost: true,
+	})
+	if err != nil {
+		t.Fatalf("NewRistrettoWithConfig() error = %v", err)
+	}
+
+	for i := range 10 {
+		key := fmt.Sprintf("user:%d", i)
+		if err := s.SetWithTags(ctx, key, i, 0, "users"); err != nil {
+			t.Fatalf("SetWithTags(%q) error = %v", key, err)
+		}
+		s.Wait(ctx)
+	}
+
+	if keys := s.trackedKeys(); len(keys) > 2 {
+		t.Errorf("tracked keys = %v, want at most the 2 keys the cache holds", keys)
+	}
+	if keys := s.index.Keys(); len(keys) > 2 {
+		t.Errorf("index holds %v, want at most the 2 keys the cache holds", keys)
+	}
+
+	if err := s.SetWithTTL(ctx, "session:1", "s", 0); err != nil {
+		t.Fatalf("SetWithTTL() error = %v", err)
+	}
+	s.Wait(ctx)
+	if err := s.DelByPrefix(ctx, "user:"); err != nil {
+		t.Fatalf("DelByPrefix() error = %v", err)
+	}
+	s.Wait(ctx)
+	for _, key := range s.trackedKeys() {
+		if key != "session:1" {
+			t.Errorf("tracked key %q, want only session:1", key)
+		}
+	}
+	if keys := s.index.Keys(); len(keys) != 0 {
+		t.Errorf("index holds %v, want no untagged key", keys)
+	}
+}
+
+func TestRistrettoSnapshot(t *testing.T) {
+	ctx := context.Background()
+	cfg := &ristretto.Config{NumCounters: 1000, MaxCost: 1 << 20, BufferItems: 64}
+	src, err := NewRistrettoWithConfig(cfg)
+	if err != nil {
+		t.Fatalf("NewRistrettoWithConfig() error = %v", err)
+	}
+	_ = src.SetWithTags(ctx, "user:1", "a", time.Hour, "users")
+	_ = src.Set(ctx, "user:2", "b")
+	src.Wait(ctx)
+
+	var buf bytes.Buffer
+	if err := src.Snapshot(&buf); err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+
+	dst, err := NewRistrettoWithConfig(cfg)
+	if err != nil {
+		t.Fatalf("NewRistrettoWithConfig() error = %v", err)
+	}
+	if err := dst.Restore(&buf); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+
+	for key, want := range map[string]string{"user:1": "a", "user:2": "b"} {
+		if value, err := dst.Get(ctx, key); err != nil || value != want {
+			t.Errorf("Get(%q) = %v, %v, want %q", key, value, err, want)
+		}
+	}
+	if ttl, _ := dst.client.GetTTL("user:1"); ttl <= 59*time.Minute {
+		t.Errorf("GetTTL(user:1) = %v, want the remaining hour", ttl)
+	}
+
+	// The tags are restored.
+	if err := dst.InvalidateTags(ctx, "users"); err != nil {
+		t.Fatalf("InvalidateTags() error = %v", err)
+	}
+	dst.Wait(ctx)
+	if _, err := dst.Get(ctx, "user:1"); err != store.ErrKeyNotFound {
+		t.Errorf("Get(user:1) error = %v, want ErrKeyNotFound", err)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package ristretto
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/snail-plus/gopkg/cache/store"
+)
+
+var _ store.Snapshotter = (*RistrettoStore)(nil)
+
+// Snapshot writes the unexpired items of the store to w. Ristretto cannot
+// enumerate its keys, so only the keys tracked by the store are part of the
+// snapshot: every string key for a store created with NewRistrettoWithConfig,
+// the tagged keys only for a store created with NewRistretto.
+func (s *RistrettoStore) Snapshot(w io.Writer) error {
+	sw, err := store.NewSnapshotWriter(w)
+	if err != nil {
+		return err
+	}
+
+	tags := s.index.Keys()
+	for _, key := range s.trackedKeys() {
+		value, exists := s.client.Get(key)
+		if !exists {
+			continue
+		}
+		ttl, exists := s.client.GetTTL(key)
+		if !exists {
+			continue
+		}
+
+		e := &store.SnapshotEntry{Key: key, Value: value, Tags: tags[key]}
+		if ttl > 0 {
+			e.ExpiresAt = time.Now().Add(ttl)
+		}
+		if err := sw.Write(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Restore adds the unexpired items of the snapshot read from r to the store,
+// with their remaining TTL. The items already in the store are kept. Restore
+// waits for the writes to be applied, so the items can be read once it returns,
+// unless ristretto rejected them.
+func (s *RistrettoStore) Restore(r io.Reader) error {
+	defer s.client.Wait()
+
+	return store.ReadSnapshot(r, func(e *store.SnapshotEntry) error {
+		if _, exists := s.client.Get(e.Key); exists {
+			return nil
+		}
+
+		// The entry may have expired since the snapshot was read.
+		ttl := e.TTL(time.Now())
+		if ttl < 0 {
+			return nil
+		}
+
+		return s.SetWithTags(context.Background(), e.Key, e.Value, ttl, e.Tags...)
+	})
+}
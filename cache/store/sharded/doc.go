@@ -0,0 +1,5 @@
+// Copyright 2024 eve.  All rights reserved.
+
+// Package sharded provides a store that spreads the keys across several
+// stores, such as standalone Redis instances, with a consistent-hash ring.
+package sharded // import "github.com/snail-plus/gopkg/cache/store/sharded"
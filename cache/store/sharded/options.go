@@ -0,0 +1,44 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package sharded
+
+import (
+	"github.com/cespare/xxhash/v2"
+)
+
+// HashFunc hashes the keys and the virtual nodes onto the ring.
+type HashFunc func(data []byte) uint64
+
+// Option represents a sharded store option function.
+type Option func(o *Options)
+
+// Options represents the options for sharded store configuration.
+type Options struct {
+	// Replicas is the number of virtual nodes of every node on the ring. More
+	// virtual nodes spread the keys more evenly, at the cost of a larger ring.
+	Replicas int
+	// Hash hashes the keys and the virtual nodes, xxhash by default.
+	Hash HashFunc
+}
+
+// WithReplicas sets the number of virtual nodes of every node.
+func WithReplicas(replicas int) Option {
+	return func(o *Options) {
+		o.Replicas = replicas
+	}
+}
+
+// WithHash sets the hash function of the ring.
+func WithHash(hash HashFunc) Option {
+	return func(o *Options) {
+		o.Hash = hash
+	}
+}
+
+// NewOptions instantiates a new Options with default values.
+func NewOptions() *Options {
+	return &Options{
+		Replicas: 160,
+		Hash:     xxhash.Sum64,
+	}
+}
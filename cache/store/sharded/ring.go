@@ -0,0 +1,59 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package sharded
+
+import (
+	"cmp"
+	"slices"
+	"sort"
+	"strconv"
+)
+
+// point is a virtual node on the ring.
+type point struct {
+	hash uint64
+	node string
+}
+
+// ring is a consistent-hash ring. A key belongs to the first virtual node
+// found clockwise from its hash, so adding or removing a node only moves
+// the keys of its own virtual nodes. Rings are immutable, they are replaced
+// as a whole when nodes are added or removed.
+type ring struct {
+	points []point
+}
+
+// newRing builds the ring of the given nodes, with replicas virtual nodes each.
+func newRing(nodes []string, replicas int, hash HashFunc) *ring {
+	points := make([]point, 0, len(nodes)*replicas)
+	for _, node := range nodes {
+		for i := 0; i < replicas; i++ {
+			points = append(points, point{hash: hash([]byte(node + "#" + strconv.Itoa(i))), node: node})
+		}
+	}
+
+	// Ties between nodes are broken by name, so that the ring does not depend
+	// on the order the nodes were added in.
+	slices.SortFunc(points, func(a, b point) int {
+		if c := cmp.Compare(a.hash, b.hash); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.node, b.node)
+	})
+
+	return &ring{points: points}
+}
+
+// locate returns the node the hash belongs to, or false if the ring is empty.
+func (r *ring) locate(hash uint64) (string, bool) {
+	if len(r.points) == 0 {
+		return "", false
+	}
+
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i].hash >= hash })
+	if i == len(r.points) {
+		i = 0
+	}
+
+	return r.points[i].node, true
+}
@@ -0,0 +1,378 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package sharded
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/snail-plus/gopkg/cache/store"
+)
+
+// ShardedType represents the storage type as a string value.
+const ShardedType = "sharded"
+
+var (
+	// ErrNoNodes is returned when the store has no node to send a key to.
+	ErrNoNodes = errors.New("sharded store has no nodes")
+	// ErrNodeExists is returned when adding a node whose name is taken.
+	ErrNodeExists = errors.New("node already exists")
+	// ErrNodeNotFound is returned when removing an unknown node.
+	ErrNodeNotFound = errors.New("node not found")
+)
+
+var _ store.Store = (*ShardedStore)(nil)
+
+// ShardedStore spreads the keys across several stores with a consistent-hash
+// ring. Every node is placed on the ring by its name, so the same names map
+// the keys to the same nodes across restarts and instances.
+//
+// Tags and prefixes are not bound to a node: InvalidateTags, DelByPrefix and
+// Clear are sent to every node.
+type ShardedStore struct {
+	opts *Options
+
+	mu    sync.RWMutex
+	nodes map[string]store.Store
+	ring  *ring
+}
+
+// NewSharded creates a new store spreading the keys across the given stores,
+// by node name. A non-positive number of replicas or a nil hash function is
+// replaced by its default.
+func NewSharded(nodes map[string]store.Store, options ...Option) *ShardedStore {
+	opts := NewOptions()
+	for _, opt := range options {
+		opt(opts)
+	}
+	defaults := NewOptions()
+	if opts.Replicas <= 0 {
+		opts.Replicas = defaults.Replicas
+	}
+	if opts.Hash == nil {
+		opts.Hash = defaults.Hash
+	}
+
+	s := &ShardedStore{opts: opts, nodes: make(map[string]store.Store, len(nodes))}
+	for name, node := range nodes {
+		s.nodes[name] = node
+	}
+	s.rebuild()
+
+	return s
+}
+
+// rebuild replaces the ring with the ring of the current nodes, s.mu must be
+// held for writing.
+func (s *ShardedStore) rebuild() {
+	names := make([]string, 0, len(s.nodes))
+	for name := range s.nodes {
+		names = append(names, name)
+	}
+
+	s.ring = newRing(names, s.opts.Replicas, s.opts.Hash)
+}
+
+// AddNode adds a node to the ring. Only the keys falling on the virtual nodes
+// of the new node move to it, they are missed once and reloaded from the
+// source of truth.
+func (s *ShardedStore) AddNode(name string, node store.Store) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.nodes[name]; ok {
+		return fmt.Errorf("%w: %s", ErrNodeExists, name)
+	}
+
+	s.nodes[name] = node
+	s.rebuild()
+	return nil
+}
+
+// RemoveNode removes a node from the ring, its keys move to the other nodes.
+func (s *ShardedStore) RemoveNode(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.nodes[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrNodeNotFound, name)
+	}
+
+	delete(s.nodes, name)
+	s.rebuild()
+	return nil
+}
+
+// Nodes returns the names of the nodes, sorted.
+func (s *ShardedStore) Nodes() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.nodes))
+	for name := range s.nodes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// Locate returns the name of the node holding the key.
+func (s *ShardedStore) Locate(key any) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	name, _, err := s.locate(key)
+	return name, err
+}
+
+// locate returns the node holding the key, s.mu must be held.
+func (s *ShardedStore) locate(key any) (string, store.Store, error) {
+	name, ok := s.ring.locate(s.opts.Hash([]byte(keyString(key))))
+	if !ok {
+		return "", nil, ErrNoNodes
+	}
+
+	return name, s.nodes[name], nil
+}
+
+// node returns the node holding the key.
+func (s *ShardedStore) node(key any) (store.Store, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, node, err := s.locate(key)
+	return node, err
+}
+
+// shard is the part of a bulk operation sent to a node.
+type shard struct {
+	node store.Store
+	keys []any
+}
+
+// shards groups the keys by the node holding them.
+func (s *ShardedStore) shards(keys []any) ([]*shard, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	byName := make(map[string]*shard)
+	shards := []*shard{}
+	for _, key := range keys {
+		name, node, err := s.locate(key)
+		if err != nil {
+			return nil, err
+		}
+		sh, ok := byName[name]
+		if !ok {
+			sh = &shard{node: node}
+			byName[name] = sh
+			shards = append(shards, sh)
+		}
+		sh.keys = append(sh.keys, key)
+	}
+
+	return shards, nil
+}
+
+// all returns a shard without keys for every node.
+func (s *ShardedStore) all() []*shard {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	shards := make([]*shard, 0, len(s.nodes))
+	for _, node := range s.nodes {
+		shards = append(shards, &shard{node: node})
+	}
+
+	return shards
+}
+
+// fanOut calls fn concurrently for every shard, and joins the errors.
+func fanOut(shards []*shard, fn func(sh *shard) error) error {
+	if len(shards) == 1 {
+		return fn(shards[0])
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var errs []error
+	for _, sh := range shards {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fn(sh); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// broadcast calls fn concurrently for every node, and joins the errors.
+func (s *ShardedStore) broadcast(fn func(node store.Store) error) error {
+	return fanOut(s.all(), func(sh *shard) error {
+		return fn(sh.node)
+	})
+}
+
+// Get returns data stored from a given key.
+func (s *ShardedStore) Get(ctx context.Context, key any) (any, error) {
+	node, err := s.node(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return node.Get(ctx, key)
+}
+
+// GetWithTTL returns data stored from a given key and its corresponding TTL.
+func (s *ShardedStore) GetWithTTL(ctx context.Context, key any) (any, time.Duration, error) {
+	node, err := s.node(key)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return node.GetWithTTL(ctx, key)
+}
+
+// Set defines data in the node of the given key.
+func (s *ShardedStore) Set(ctx context.Context, key any, value any) error {
+	node, err := s.node(key)
+	if err != nil {
+		return err
+	}
+
+	return node.Set(ctx, key, value)
+}
+
+// SetWithTTL defines data in the node of the given key with the given TTL.
+func (s *ShardedStore) SetWithTTL(ctx context.Context, key any, value any, ttl time.Duration) error {
+	node, err := s.node(key)
+	if err != nil {
+		return err
+	}
+
+	return node.SetWithTTL(ctx, key, value, ttl)
+}
+
+// SetWithTags defines data in the node of the given key and attaches the tags to it.
+func (s *ShardedStore) SetWithTags(ctx context.Context, key any, value any, ttl time.Duration, tags ...string) error {
+	node, err := s.node(key)
+	if err != nil {
+		return err
+	}
+
+	return node.SetWithTags(ctx, key, value, ttl, tags...)
+}
+
+// Del removes data in the node of the given key.
+func (s *ShardedStore) Del(ctx context.Context, key any) error {
+	node, err := s.node(key)
+	if err != nil {
+		return err
+	}
+
+	return node.Del(ctx, key)
+}
+
+// GetMany returns data stored from the given keys, with one concurrent bulk
+// read per node. The values read from the healthy nodes are returned along
+// with the errors of the others.
+func (s *ShardedStore) GetMany(ctx context.Context, keys ...any) (map[any]any, error) {
+	shards, err := s.shards(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	values := make(map[any]any, len(keys))
+	err = fanOut(shards, func(sh *shard) error {
+		found, err := sh.node.GetMany(ctx, sh.keys...)
+		mu.Lock()
+		defer mu.Unlock()
+		for key, value := range found {
+			values[key] = value
+		}
+		return err
+	})
+
+	return values, err
+}
+
+// SetMany defines data for all given keys, with one concurrent bulk write per node.
+func (s *ShardedStore) SetMany(ctx context.Context, items map[any]any, ttl time.Duration) error {
+	keys := make([]any, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	shards, err := s.shards(keys)
+	if err != nil {
+		return err
+	}
+
+	return fanOut(shards, func(sh *shard) error {
+		shardItems := make(map[any]any, len(sh.keys))
+		for _, key := range sh.keys {
+			shardItems[key] = items[key]
+		}
+		return sh.node.SetMany(ctx, shardItems, ttl)
+	})
+}
+
+// DelMany removes data for all given keys, with one concurrent bulk delete per node.
+func (s *ShardedStore) DelMany(ctx context.Context, keys ...any) error {
+	shards, err := s.shards(keys)
+	if err != nil {
+		return err
+	}
+
+	return fanOut(shards, func(sh *shard) error {
+		return sh.node.DelMany(ctx, sh.keys...)
+	})
+}
+
+// InvalidateTags removes data attached to any of the given tags, on every node.
+func (s *ShardedStore) InvalidateTags(ctx context.Context, tags ...string) error {
+	return s.broadcast(func(node store.Store) error {
+		return node.InvalidateTags(ctx, tags...)
+	})
+}
+
+// DelByPrefix removes data whose key starts with the given prefix, on every node.
+func (s *ShardedStore) DelByPrefix(ctx context.Context, prefix string) error {
+	return s.broadcast(func(node store.Store) error {
+		return node.DelByPrefix(ctx, prefix)
+	})
+}
+
+// Clear resets all data of every node.
+func (s *ShardedStore) Clear(ctx context.Context) error {
+	return s.broadcast(func(node store.Store) error {
+		return node.Clear(ctx)
+	})
+}
+
+// Wait waits for the pending operations of every node.
+func (s *ShardedStore) Wait(ctx context.Context) {
+	for _, sh := range s.all() {
+		sh.node.Wait(ctx)
+	}
+}
+
+// keyString returns the string hashed to place the key on the ring.
+func keyString(key any) string {
+	if k, ok := key.(string); ok {
+		return k
+	}
+
+	return fmt.Sprint(key)
+}
@@ -0,0 +1,128 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package sharded
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/snail-plus/gopkg/cache/store"
+	"github.com/snail-plus/gopkg/cache/store/memory"
+)
+
+func newTestNodes(t *testing.T, names ...string) map[string]store.Store {
+	nodes := make(map[string]store.Store, len(names))
+	for _, name := range names {
+		s := memory.NewMemory()
+		t.Cleanup(func() { _ = s.Close() })
+		nodes[name] = s
+	}
+	return nodes
+}
+
+func TestShardedRebalance(t *testing.T) {
+	const keys = 10000
+	s := NewSharded(newTestNodes(t, "a", "b", "c"))
+
+	before := make(map[string]string, keys)
+	counts := map[string]int{}
+	for i := 0; i < keys; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		node, err := s.Locate(key)
+		if err != nil {
+			t.Fatalf("Locate() error = %v", err)
+		}
+		before[key] = node
+		counts[node]++
+	}
+	for node, n := range counts {
+		if n < keys/3*7/10 || n > keys/3*13/10 {
+			t.Errorf("node %s holds %d keys, want about %d", node, n, keys/3)
+		}
+	}
+
+	if err := s.AddNode("d", memory.NewMemory()); err != nil {
+		t.Fatalf("AddNode() error = %v", err)
+	}
+	moved := 0
+	for key, old := range before {
+		node, _ := s.Locate(key)
+		if node != old {
+			if node != "d" {
+				t.Fatalf("key %s moved from %s to %s, want keys to only move to the new node", key, old, node)
+			}
+			moved++
+		}
+	}
+	if moved < keys/4*7/10 || moved > keys/4*13/10 {
+		t.Errorf("%d keys moved, want about %d", moved, keys/4)
+	}
+
+	// Removing the node brings the keys back where they were.
+	if err := s.RemoveNode("d"); err != nil {
+		t.Fatalf("RemoveNode() error = %v", err)
+	}
+	for key, old := range before {
+		if node, _ := s.Locate(key); node != old {
+			t.Fatalf("key %s is on %s after removing d, want %s", key, node, old)
+		}
+	}
+}
+
+func TestShardedInvalidOptions(t *testing.T) {
+	s := NewSharded(newTestNodes(t, "a", "b"), WithReplicas(0), WithHash(nil))
+	if s.opts.Replicas <= 0 || s.opts.Hash == nil {
+		t.Fatalf("options = %+v, want the defaults", s.opts)
+	}
+	if _, err := s.Locate("key"); err != nil {
+		t.Errorf("Locate() error = %v", err)
+	}
+}
+
+func TestShardedBulk(t *testing.T) {
+	ctx := context.Background()
+	nodes := newTestNodes(t, "a", "b", "c")
+	s := NewSharded(nodes)
+
+	items := map[any]any{}
+	keys := []any{}
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		items[key] = i
+		keys = append(keys, key)
+	}
+	if err := s.SetMany(ctx, items, 0); err != nil {
+		t.Fatalf("SetMany() error = %v", err)
+	}
+
+	// Every key is written to its own node only.
+	for _, key := range keys {
+		name, _ := s.Locate(key)
+		for n, node := range nodes {
+			_, err := node.Get(ctx, key)
+			if (n == name) != (err == nil) {
+				t.Fatalf("key %s found on %s = %v, want it on %s only", key, n, err == nil, name)
+			}
+		}
+	}
+
+	values, err := s.GetMany(ctx, append(keys, "missing")...)
+	if err != nil || len(values) != len(items) {
+		t.Fatalf("GetMany() = %d values, %v, want %d", len(values), err, len(items))
+	}
+
+	if err := s.DelMany(ctx, keys[:50]...); err != nil {
+		t.Fatalf("DelMany() error = %v", err)
+	}
+	if values, _ := s.GetMany(ctx, keys...); len(values) != 50 {
+		t.Errorf("GetMany() = %d values after DelMany, want 50", len(values))
+	}
+
+	if err := s.DelByPrefix(ctx, "key-"); err != nil {
+		t.Fatalf("DelByPrefix() error = %v", err)
+	}
+	if values, _ := s.GetMany(ctx, keys...); len(values) != 0 {
+		t.Errorf("GetMany() = %d values after DelByPrefix, want 0", len(values))
+	}
+}
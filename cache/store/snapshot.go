@@ -0,0 +1,152 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package store
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshotVersion is the version of the snapshot format.
+const snapshotVersion = 1
+
+// ErrSnapshotVersion is returned when restoring a snapshot written in an unknown format.
+var ErrSnapshotVersion = errors.New("unsupported snapshot version")
+
+// Snapshotter is implemented by the in-memory stores that can dump their
+// content and load it back, so that a restarted service does not start with
+// an empty cache.
+//
+// Snapshots are gob encoded: the types of the values other than the basic
+// ones must be registered with gob.Register before taking or restoring one.
+type Snapshotter interface {
+	// Snapshot writes the unexpired items of the store to w.
+	Snapshot(w io.Writer) error
+	// Restore adds the unexpired items of the snapshot read from r to the store.
+	Restore(r io.Reader) error
+}
+
+// SnapshotEntry is an item of a snapshot.
+type SnapshotEntry struct {
+	Key   string
+	Value any
+	// ExpiresAt is the expiration time of the item, zero if it never expires.
+	ExpiresAt time.Time
+	Tags      []string
+}
+
+// TTL returns the time left before the entry expires, zero if it never
+// expires, or a negative duration if it already has.
+func (e *SnapshotEntry) TTL(now time.Time) time.Duration {
+	if e.ExpiresAt.IsZero() {
+		return 0
+	}
+
+	if ttl := e.ExpiresAt.Sub(now); ttl > 0 {
+		return ttl
+	}
+	return -1
+}
+
+// SnapshotWriter encodes the entries of a snapshot.
+type SnapshotWriter struct {
+	enc *gob.Encoder
+}
+
+// NewSnapshotWriter starts a snapshot written to w.
+func NewSnapshotWriter(w io.Writer) (*SnapshotWriter, error) {
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(snapshotVersion); err != nil {
+		return nil, err
+	}
+
+	return &SnapshotWriter{enc: enc}, nil
+}
+
+// Write encodes the entry.
+func (w *SnapshotWriter) Write(e *SnapshotEntry) error {
+	if err := w.enc.Encode(e); err != nil {
+		return fmt.Errorf("failed to encode snapshot entry %q: %w", e.Key, err)
+	}
+
+	return nil
+}
+
+// ReadSnapshot decodes the snapshot read from r and calls fn with every
+// entry that has not expired yet.
+func ReadSnapshot(r io.Reader, fn func(e *SnapshotEntry) error) error {
+	dec := gob.NewDecoder(r)
+
+	var version int
+	if err := dec.Decode(&version); err != nil {
+		return fmt.Errorf("failed to decode snapshot version: %w", err)
+	}
+	if version != snapshotVersion {
+		return fmt.Errorf("%w: %d", ErrSnapshotVersion, version)
+	}
+
+	now := time.Now()
+	for {
+		e := &SnapshotEntry{}
+		if err := dec.Decode(e); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("failed to decode snapshot entry: %w", err)
+		}
+
+		if e.TTL(now) < 0 {
+			continue
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+}
+
+// SaveSnapshot writes a snapshot of the store to the file at path. The file
+// is replaced atomically, a failed snapshot leaves the previous one intact.
+func SaveSnapshot(s Snapshotter, path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	w := bufio.NewWriter(tmp)
+	if err := s.Snapshot(w); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadSnapshot restores the snapshot saved to the file at path into the
+// store. The error wraps fs.ErrNotExist when there is no snapshot yet.
+func LoadSnapshot(s Snapshotter, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return s.Restore(bufio.NewReader(f))
+}
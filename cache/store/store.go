@@ -17,6 +17,19 @@ type Store interface {
 	Set(ctx context.Context, key any, value any) error
 	SetWithTTL(ctx context.Context, key any, value any, ttl time.Duration) error
 	Del(ctx context.Context, key any) error
+	// GetMany returns the values of the given keys found in the store. Keys
+	// that are not found are absent from the returned map.
+	GetMany(ctx context.Context, keys ...any) (map[any]any, error)
+	// SetMany stores all the given values with the same TTL.
+	SetMany(ctx context.Context, items map[any]any, ttl time.Duration) error
+	// DelMany removes the items of all the given keys.
+	DelMany(ctx context.Context, keys ...any) error
+	// SetWithTags stores the value with the given TTL and attaches the tags to it.
+	SetWithTags(ctx context.Context, key any, value any, ttl time.Duration, tags ...string) error
+	// InvalidateTags removes the items attached to any of the given tags.
+	InvalidateTags(ctx context.Context, tags ...string) error
+	// DelByPrefix removes the items whose key starts with the given prefix.
+	DelByPrefix(ctx context.Context, prefix string) error
 	// Clear removes items that have an expired TTL.
 	Clear(ctx context.Context) error
 	Wait(ctx context.Context)
@@ -11,36 +11,63 @@ import (
 
 // RedisOptions defines optsions for mysql database.
 type RedisOptions struct {
-	Addr         string
-	Username     string
-	Password     string
-	Database     int
-	MaxRetries   int
-	MinIdleConns int
-	DialTimeout  time.Duration
-	ReadTimeout  time.Duration
-	WriteTimeout time.Duration
-	PoolTimeout  time.Duration
-	PoolSize     int
+	Addr string
+	// Addrs holds the seed addresses of a cluster, or the sentinel addresses
+	// when MasterName is set. When empty, Addr is used.
+	Addrs []string
+	// MasterName is the name of the master monitored by the sentinels.
+	MasterName string
+	// IsClusterMode creates a cluster client even when a single address,
+	// such as a configuration endpoint, is given.
+	IsClusterMode bool
+	// RouteByLatency and RouteRandomly route read-only commands of a
+	// cluster, or of the master and replicas monitored by the sentinels, to
+	// the closest or a random node.
+	RouteByLatency bool
+	RouteRandomly  bool
+	Username       string
+	Password       string
+	Database       int
+	MaxRetries     int
+	MinIdleConns   int
+	DialTimeout    time.Duration
+	ReadTimeout    time.Duration
+	WriteTimeout   time.Duration
+	PoolTimeout    time.Duration
+	PoolSize       int
 }
 
-// NewRedis create a new gorm db instance with the given options.
-func NewRedis(opts *RedisOptions) (*redis.Client, error) {
-	options := &redis.Options{
-		Addr:         opts.Addr,
-		Username:     opts.Username,
-		Password:     opts.Password,
-		DB:           opts.Database,
-		MaxRetries:   opts.MaxRetries,
-		MinIdleConns: opts.MinIdleConns,
-		DialTimeout:  opts.DialTimeout,
-		ReadTimeout:  opts.ReadTimeout,
-		WriteTimeout: opts.WriteTimeout,
-		PoolTimeout:  opts.PoolTimeout,
-		PoolSize:     opts.PoolSize,
+// NewRedis create a new redis client with the given options. When MasterName
+// is set, a sentinel (failover) client is returned, or a failover cluster
+// client routing read-only commands to the replicas when IsClusterMode,
+// RouteByLatency or RouteRandomly is also set. Otherwise a cluster client is
+// returned when IsClusterMode is set or several addresses are given, and a
+// standalone client in any other case.
+func NewRedis(opts *RedisOptions) (redis.UniversalClient, error) {
+	addrs := opts.Addrs
+	if len(addrs) == 0 {
+		addrs = []string{opts.Addr}
 	}
 
-	rdb := redis.NewClient(options)
+	options := &redis.UniversalOptions{
+		Addrs:          addrs,
+		MasterName:     opts.MasterName,
+		IsClusterMode:  opts.IsClusterMode,
+		RouteByLatency: opts.RouteByLatency,
+		RouteRandomly:  opts.RouteRandomly,
+		Username:       opts.Username,
+		Password:       opts.Password,
+		DB:             opts.Database,
+		MaxRetries:     opts.MaxRetries,
+		MinIdleConns:   opts.MinIdleConns,
+		DialTimeout:    opts.DialTimeout,
+		ReadTimeout:    opts.ReadTimeout,
+		WriteTimeout:   opts.WriteTimeout,
+		PoolTimeout:    opts.PoolTimeout,
+		PoolSize:       opts.PoolSize,
+	}
+
+	rdb := redis.NewUniversalClient(options)
 
 	// check redis if is ok
 	if _, err := rdb.Ping(context.Background()).Result(); err != nil {
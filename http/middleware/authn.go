@@ -0,0 +1,260 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package middleware
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	gojwt "github.com/golang-jwt/jwt/v5"
+
+	"github.com/snail-plus/gopkg/authn"
+	"github.com/snail-plus/gopkg/authn/jwt"
+	xhttp "github.com/snail-plus/gopkg/http"
+	"github.com/snail-plus/gopkg/i18n"
+	"github.com/snail-plus/gopkg/log"
+)
+
+// claimsContextKey is the gin context key of the claims set by Authn.
+const claimsContextKey = "gopkg/http/middleware/claims"
+
+// AuthnOption represents an authentication option function.
+type AuthnOption func(o *AuthnOptions)
+
+// AuthnOptions represents the options for authentication configuration.
+type AuthnOptions struct {
+	// TokenLookup is a comma separated list of source:name pairs telling
+	// where the token is looked up, in order. The sources are header, cookie
+	// and query. Query tokens end up in access logs and Referer headers, only
+	// look them up when there is no other way, such as for websockets.
+	TokenLookup string
+	// TokenHeadName is the scheme prefixing the tokens read from a header.
+	TokenHeadName string
+	// PublicPaths are the paths served without authentication. A path ending
+	// with "*" matches all the paths starting with it.
+	PublicPaths []string
+	// ParseClaims verifies the token and returns its claims. By default, the
+	// claims are parsed with ParseCustomClaims when the verifier has it, like
+	// JWTAuth, so that the custom claims reach the handlers, and with
+	// ParseClaims otherwise.
+	ParseClaims func(ctx context.Context, token string) (gojwt.Claims, error)
+	// OnError answers the requests that fail authentication. By default, the
+	// error is answered with http.Api.Failure and the request is aborted.
+	OnError func(c *gin.Context, err error)
+}
+
+// AuthnWithTokenLookup sets where the token is looked up, such as "header:Authorization,cookie:jwt".
+func AuthnWithTokenLookup(lookup string) AuthnOption {
+	return func(o *AuthnOptions) {
+		o.TokenLookup = lookup
+	}
+}
+
+// AuthnWithTokenHeadName sets the scheme prefixing the tokens read from a header.
+func AuthnWithTokenHeadName(name string) AuthnOption {
+	return func(o *AuthnOptions) {
+		o.TokenHeadName = name
+	}
+}
+
+// AuthnWithPublicPaths sets the paths served without authentication.
+func AuthnWithPublicPaths(paths ...string) AuthnOption {
+	return func(o *AuthnOptions) {
+		o.PublicPaths = paths
+	}
+}
+
+// AuthnWithParseClaims sets the function verifying the token and returning its
+// claims, such as one decoding them into custom claims with
+// JWKSVerifier.ParseClaimsInto.
+func AuthnWithParseClaims(parse func(ctx context.Context, token string) (gojwt.Claims, error)) AuthnOption {
+	return func(o *AuthnOptions) {
+		o.ParseClaims = parse
+	}
+}
+
+// AuthnWithErrorHandler sets the function answering the requests that fail authentication.
+func AuthnWithErrorHandler(onError func(c *gin.Context, err error)) AuthnOption {
+	return func(o *AuthnOptions) {
+		o.OnError = onError
+	}
+}
+
+// NewAuthnOptions instantiates a new AuthnOptions with default values.
+func NewAuthnOptions() *AuthnOptions {
+	return &AuthnOptions{
+		TokenLookup:   "header:Authorization,cookie:jwt",
+		TokenHeadName: "Bearer",
+		OnError: func(c *gin.Context, err error) {
+			xhttp.Api{}.Failure(c, err)
+			c.Abort()
+		},
+	}
+}
+
+// tokenSource is a place a token is looked up in.
+type tokenSource struct {
+	from string
+	name string
+}
+
+// customClaimsParser is implemented by the verifiers returning the custom
+// claims of the tokens, like JWTAuth.
+type customClaimsParser interface {
+	ParseCustomClaims(ctx context.Context, token string) (gojwt.Claims, error)
+}
+
+// Authn authenticates the requests with the token verified by the verifier,
+// which may be a JWTAuth or a JWKSVerifier. The claims of the token are
+// available to the handlers through Claims, and the subject is added to the
+// logger of the request.
+func Authn(v authn.Verifier, options ...AuthnOption) gin.HandlerFunc {
+	opts := NewAuthnOptions()
+	for _, opt := range options {
+		opt(opts)
+	}
+	if opts.ParseClaims == nil {
+		if p, ok := v.(customClaimsParser); ok {
+			opts.ParseClaims = p.ParseCustomClaims
+		} else {
+			opts.ParseClaims = func(ctx context.Context, token string) (gojwt.Claims, error) {
+				return v.ParseClaims(ctx, token)
+			}
+		}
+	}
+
+	var sources []tokenSource
+	for _, pair := range strings.Split(opts.TokenLookup, ",") {
+		from, name, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if ok && name != "" {
+			sources = append(sources, tokenSource{from: strings.TrimSpace(from), name: strings.TrimSpace(name)})
+		}
+	}
+
+	return func(c *gin.Context) {
+		if isPublicPath(c.Request.URL.Path, opts.PublicPaths) {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		token := lookupToken(c, sources, opts.TokenHeadName)
+		if token == "" {
+			opts.OnError(c, errors.New(i18n.FromContext(ctx).LocalizeT(jwt.MessageTokenMissing)))
+			return
+		}
+
+		claims, err := opts.ParseClaims(ctx, token)
+		if err != nil {
+			opts.OnError(c, err)
+			return
+		}
+
+		c.Set(claimsContextKey, claims)
+		subject, _ := claims.GetSubject()
+		keyvals := []any{"userID", subject}
+		if id := tokenID(claims); id != "" {
+			keyvals = append(keyvals, "tokenID", id)
+		}
+		log.WithContext(c, keyvals...)
+		c.Request = c.Request.WithContext(log.WithContext(ctx, keyvals...))
+
+		c.Next()
+	}
+}
+
+// Claims returns the claims of the token of the request, set by Authn. Their
+// type is the one returned by the parse function, a *jwt.MapClaims by default
+// for a JWTAuth, see ClaimsAs.
+func Claims(c *gin.Context) (gojwt.Claims, bool) {
+	claims, ok := c.Get(claimsContextKey)
+	if !ok {
+		return nil, false
+	}
+
+	parsed, ok := claims.(gojwt.Claims)
+	return parsed, ok
+}
+
+// ClaimsAs returns the claims of the token of the request as a C, such as
+// *jwt.MapClaims or a pointer to the claims type of the claims factory.
+func ClaimsAs[C gojwt.Claims](c *gin.Context) (C, bool) {
+	claims, ok := Claims(c)
+	if !ok {
+		return *new(C), false
+	}
+
+	typed, ok := claims.(C)
+	return typed, ok
+}
+
+// Subject returns the subject of the token of the request, usually the user ID.
+func Subject(c *gin.Context) string {
+	if claims, ok := Claims(c); ok {
+		subject, _ := claims.GetSubject()
+		return subject
+	}
+
+	return ""
+}
+
+// tokenID returns the jti claim of the usual claims types.
+func tokenID(claims gojwt.Claims) string {
+	switch c := claims.(type) {
+	case *gojwt.RegisteredClaims:
+		return c.ID
+	case *gojwt.MapClaims:
+		id, _ := (*c)["jti"].(string)
+		return id
+	case gojwt.MapClaims:
+		id, _ := c["jti"].(string)
+		return id
+	default:
+		return ""
+	}
+}
+
+// lookupToken returns the first token found in the sources.
+func lookupToken(c *gin.Context, sources []tokenSource, headName string) string {
+	for _, source := range sources {
+		var token string
+		switch source.from {
+		case "header":
+			token = c.GetHeader(source.name)
+			if headName != "" {
+				scheme, credentials, ok := strings.Cut(token, " ")
+				if !ok || !strings.EqualFold(scheme, headName) {
+					continue
+				}
+				token = credentials
+			}
+		case "cookie":
+			token, _ = c.Cookie(source.name)
+		case "query":
+			token = c.Query(source.name)
+		}
+
+		if token = strings.TrimSpace(token); token != "" {
+			return token
+		}
+	}
+
+	return ""
+}
+
+// isPublicPath reports whether the path is served without authentication.
+func isPublicPath(path string, publicPaths []string) bool {
+	for _, public := range publicPaths {
+		if prefix, ok := strings.CutSuffix(public, "*"); ok {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		} else if path == public {
+			return true
+		}
+	}
+
+	return false
+}
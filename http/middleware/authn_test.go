@@ -0,0 +1,71 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	gojwt "github.com/golang-jwt/jwt/v5"
+
+	"github.com/snail-plus/gopkg/authn/jwt"
+)
+
+func TestAuthn(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	a := jwt.New(nil, jwt.WithSigningKey([]byte("secret")))
+	token, err := a.Sign(context.Background(), "alice", map[string]any{"tenant": "acme"})
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	handler := func(c *gin.Context) { c.String(http.StatusOK, "hello "+Subject(c)) }
+	r := gin.New()
+	r.GET("/ws", Authn(a, AuthnWithTokenLookup("query:token")), handler)
+	r.GET("/tenant", Authn(a), func(c *gin.Context) {
+		// The custom claims reach the handlers.
+		claims, _ := ClaimsAs[*gojwt.MapClaims](c)
+		tenant, _ := (*claims)["tenant"].(string)
+		c.String(http.StatusOK, "hello "+tenant)
+	})
+	r.Use(Authn(a, AuthnWithPublicPaths("/healthz", "/public/*")))
+	r.GET("/me", handler)
+	r.GET("/healthz", handler)
+	r.GET("/public/docs", handler)
+
+	tests := []struct {
+		name   string
+		target string
+		header http.Header
+		want   string
+	}{
+		{"header", "/me", http.Header{"Authorization": {"Bearer " + token.GetToken()}}, "hello alice"},
+		{"cookie", "/me", http.Header{"Cookie": {"jwt=" + token.GetToken()}}, "hello alice"},
+		{"query not looked up by default", "/me?token=" + token.GetToken(), nil, jwt.ErrTokenMissing.Error()},
+		{"query", "/ws?token=" + token.GetToken(), nil, "hello alice"},
+		{"custom claims", "/tenant", http.Header{"Authorization": {"Bearer " + token.GetToken()}}, "hello acme"},
+		{"missing", "/me", nil, jwt.ErrTokenMissing.Error()},
+		{"wrong scheme", "/me", http.Header{"Authorization": {"Basic " + token.GetToken()}}, jwt.ErrTokenMissing.Error()},
+		{"invalid", "/me", http.Header{"Authorization": {"Bearer invalid"}}, jwt.ErrTokenInvalid.Error()},
+		{"public", "/healthz", nil, "hello "},
+		{"public prefix", "/public/docs", nil, "hello "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(r, tt.target, tt.header)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+			if body := w.Body.String(); !strings.Contains(body, tt.want) {
+				t.Errorf("body = %q, want it to contain %q", body, tt.want)
+			}
+			if strings.HasPrefix(tt.want, "hello") != strings.HasPrefix(w.Body.String(), "hello") {
+				t.Errorf("body = %q, handler reached unexpectedly", w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,325 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/snail-plus/gopkg/cache"
+	"github.com/snail-plus/gopkg/cache/codec"
+	"github.com/snail-plus/gopkg/cache/store"
+	"github.com/snail-plus/gopkg/log"
+)
+
+// ttlContextKey is the gin context key of the TTL set by CacheTTL.
+const ttlContextKey = "gopkg/http/middleware/cache-ttl"
+
+// CacheOption represents a response cache option function.
+type CacheOption func(o *CacheOptions)
+
+// CacheOptions represents the options for response cache configuration.
+type CacheOptions struct {
+	// TTL is how long responses are cached. Handlers may override it per
+	// request with CacheTTL.
+	TTL time.Duration
+	// KeyPrefix is prepended to the cache keys.
+	KeyPrefix string
+	// VaryHeaders are the request headers that are part of the cache key,
+	// such as Accept-Language.
+	VaryHeaders []string
+	// Statuses are the response status codes that are cached.
+	Statuses []int
+	// Authenticated caches the responses of the requests carrying an
+	// Authorization or a Cookie header. Such responses are usually specific
+	// to a user, so they bypass the cache by default; when enabled, add the
+	// headers telling the users apart to VaryHeaders unless the responses
+	// really are shared.
+	Authenticated bool
+}
+
+// CacheWithTTL sets how long responses are cached.
+func CacheWithTTL(ttl time.Duration) CacheOption {
+	return func(o *CacheOptions) {
+		o.TTL = ttl
+	}
+}
+
+// CacheWithKeyPrefix sets the prefix of the cache keys.
+func CacheWithKeyPrefix(prefix string) CacheOption {
+	return func(o *CacheOptions) {
+		o.KeyPrefix = prefix
+	}
+}
+
+// CacheWithVaryHeaders sets the request headers that are part of the cache key.
+func CacheWithVaryHeaders(headers ...string) CacheOption {
+	return func(o *CacheOptions) {
+		o.VaryHeaders = headers
+	}
+}
+
+// CacheWithAuthenticated caches the responses of the requests carrying credentials.
+func CacheWithAuthenticated(authenticated bool) CacheOption {
+	return func(o *CacheOptions) {
+		o.Authenticated = authenticated
+	}
+}
+
+// CacheWithStatuses sets the response status codes that are cached.
+func CacheWithStatuses(statuses ...int) CacheOption {
+	return func(o *CacheOptions) {
+		o.Statuses = statuses
+	}
+}
+
+// NewCacheOptions instantiates a new CacheOptions with default values.
+func NewCacheOptions() *CacheOptions {
+	return &CacheOptions{
+		TTL:       time.Minute,
+		KeyPrefix: "http-cache:",
+		Statuses:  []int{http.StatusOK},
+	}
+}
+
+// CacheTTL overrides the TTL of the response of the current request. A zero
+// or negative TTL prevents the response from being cached.
+func CacheTTL(c *gin.Context, ttl time.Duration) {
+	c.Set(ttlContextKey, ttl)
+}
+
+// cachedResponse is a response as stored in the cache.
+type cachedResponse struct {
+	Status int         `msgpack:"status"`
+	Header http.Header `msgpack:"header"`
+	Body   []byte      `msgpack:"body"`
+}
+
+// responseRecorder copies the body written by the handlers.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Cache caches the full responses of GET and HEAD requests: status, headers
+// and body. Requests sent with "Cache-Control: no-cache" skip the cached
+// response, and refresh it. Responses sent with "Cache-Control: no-store" or
+// "private", or carrying cookies, are never cached. As a shared cache, it is
+// bypassed by the requests carrying an Authorization or a Cookie header,
+// unless CacheOptions.Authenticated is set.
+//
+// Cached responses get an ETag, so that clients can revalidate them with
+// If-None-Match. Concurrent misses of the same key are coalesced: a single
+// request runs the handlers, and the others are served its response.
+func Cache(c cache.Cache[[]byte], options ...CacheOption) gin.HandlerFunc {
+	opts := NewCacheOptions()
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	var group singleflight.Group
+	return func(ctx *gin.Context) {
+		if ctx.Request.Method != http.MethodGet && ctx.Request.Method != http.MethodHead {
+			ctx.Next()
+			return
+		}
+		if !opts.Authenticated && hasCredentials(ctx.Request.Header) {
+			ctx.Next()
+			return
+		}
+
+		key := opts.KeyPrefix + cacheKey(ctx.Request, opts.VaryHeaders)
+		if !hasDirective(ctx.Request.Header, "no-cache") {
+			if resp, ok := lookup(ctx, c, key); ok {
+				serve(ctx, resp)
+				return
+			}
+		}
+
+		leader := false
+		res, _, _ := group.Do(key, func() (any, error) {
+			leader = true
+			return record(ctx, c, opts, key), nil
+		})
+		if leader {
+			return
+		}
+
+		// The leader response could not be shared, run the handlers.
+		resp, _ := res.(*cachedResponse)
+		if resp == nil {
+			ctx.Next()
+			return
+		}
+		serve(ctx, resp)
+	}
+}
+
+// lookup reads the cached response of the key.
+func lookup(ctx *gin.Context, c cache.Cache[[]byte], key string) (*cachedResponse, bool) {
+	data, err := c.Get(ctx.Request.Context(), key)
+	if err != nil {
+		if !errors.Is(err, store.ErrKeyNotFound) {
+			log.C(ctx).Warnw("Failed to read cached response", "key", key, "err", err)
+		}
+		return nil, false
+	}
+
+	resp := &cachedResponse{}
+	if err := codec.Msgpack.Unmarshal(data, resp); err != nil {
+		log.C(ctx).Warnw("Failed to decode cached response", "key", key, "err", err)
+		return nil, false
+	}
+
+	return resp, true
+}
+
+// record runs the handlers and caches their response if it is cacheable. It
+// returns the response, or nil if it is not cacheable.
+func record(ctx *gin.Context, c cache.Cache[[]byte], opts *CacheOptions, key string) *cachedResponse {
+	recorder := &responseRecorder{ResponseWriter: ctx.Writer}
+	ctx.Writer = recorder
+	ctx.Writer.Header().Set("X-Cache", "MISS")
+	ctx.Next()
+	ctx.Writer = recorder.ResponseWriter
+
+	ttl := opts.TTL
+	if value, ok := ctx.Get(ttlContextKey); ok {
+		ttl, _ = value.(time.Duration)
+	}
+
+	header := recorder.Header()
+	if ttl <= 0 || !slices.Contains(opts.Statuses, recorder.Status()) ||
+		hasDirective(header, "no-store") || hasDirective(header, "private") || header.Get("Set-Cookie") != "" ||
+		hasDirective(ctx.Request.Header, "no-store") {
+		return nil
+	}
+
+	resp := &cachedResponse{
+		Status: recorder.Status(),
+		Header: header.Clone(),
+		Body:   recorder.body.Bytes(),
+	}
+	resp.Header.Del("X-Cache")
+	if resp.Header.Get("ETag") == "" {
+		sum := sha256.Sum256(resp.Body)
+		resp.Header.Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	}
+
+	data, err := codec.Msgpack.Marshal(resp)
+	if err == nil {
+		err = c.SetWithTTL(ctx.Request.Context(), key, data, ttl)
+	}
+	if err != nil {
+		log.C(ctx).Warnw("Failed to cache response", "key", key, "err", err)
+	}
+
+	return resp
+}
+
+// serve writes a cached response, or 304 Not Modified if the client already has it.
+func serve(ctx *gin.Context, resp *cachedResponse) {
+	header := ctx.Writer.Header()
+	etag := resp.Header.Get("ETag")
+	if etag != "" && etagMatch(ctx.Request.Header.Get("If-None-Match"), etag) {
+		header.Set("ETag", etag)
+		header.Set("X-Cache", "HIT")
+		ctx.AbortWithStatus(http.StatusNotModified)
+		return
+	}
+
+	for name, values := range resp.Header {
+		header[name] = values
+	}
+	header.Set("X-Cache", "HIT")
+	ctx.Status(resp.Status)
+	if ctx.Request.Method != http.MethodHead {
+		_, _ = ctx.Writer.Write(resp.Body)
+	}
+	ctx.Abort()
+}
+
+// cacheKey returns the cache key of a request, made of its method, path,
+// normalised query and the values of the vary headers.
+func cacheKey(req *http.Request, varyHeaders []string) string {
+	var b strings.Builder
+	b.WriteString(req.Method)
+	b.WriteString(" ")
+	b.WriteString(req.URL.Path)
+	b.WriteString("?")
+	b.WriteString(normalizeQuery(req.URL.Query()))
+	for _, name := range varyHeaders {
+		b.WriteString("\n")
+		b.WriteString(http.CanonicalHeaderKey(name))
+		b.WriteString(": ")
+		b.WriteString(strings.Join(req.Header.Values(name), ","))
+	}
+
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeQuery encodes the query with its keys and values sorted, so that
+// the same query written in a different order has the same key.
+func normalizeQuery(query url.Values) string {
+	for _, values := range query {
+		sort.Strings(values)
+	}
+
+	// Encode sorts the keys.
+	return query.Encode()
+}
+
+// hasCredentials reports whether the request carries credentials, which make
+// its response specific to a user.
+func hasCredentials(header http.Header) bool {
+	return header.Get("Authorization") != "" || header.Get("Cookie") != ""
+}
+
+// hasDirective reports whether the Cache-Control or Pragma headers hold the directive.
+func hasDirective(header http.Header, directive string) bool {
+	for _, name := range []string{"Cache-Control", "Pragma"} {
+		for _, value := range header.Values(name) {
+			for _, d := range strings.Split(value, ",") {
+				if strings.EqualFold(strings.TrimSpace(d), directive) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// etagMatch reports whether the If-None-Match header matches the ETag.
+func etagMatch(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,141 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/snail-plus/gopkg/cache"
+	"github.com/snail-plus/gopkg/cache/store/memory"
+)
+
+func newCacheRouter(t *testing.T, calls *atomic.Int32, release <-chan struct{}) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	s := memory.NewMemory()
+	t.Cleanup(func() { _ = s.Close() })
+
+	r := gin.New()
+	r.GET("/items", Cache(cache.New[[]byte](s), CacheWithTTL(time.Minute)), func(c *gin.Context) {
+		calls.Add(1)
+		if release != nil {
+			<-release
+		}
+		c.String(http.StatusOK, "items "+c.Query("page"))
+	})
+	return r
+}
+
+func get(r http.Handler, target string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCache(t *testing.T) {
+	var calls atomic.Int32
+	r := newCacheRouter(t, &calls, nil)
+
+	if w := get(r, "/items?page=1&size=10", nil); w.Code != http.StatusOK || w.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("first request = %d %q, want a 200 miss", w.Code, w.Header().Get("X-Cache"))
+	}
+
+	// The query is normalised.
+	w := get(r, "/items?size=10&page=1", nil)
+	if w.Body.String() != "items 1" || w.Header().Get("X-Cache") != "HIT" {
+		t.Fatalf("second request = %q %q, want a hit", w.Body.String(), w.Header().Get("X-Cache"))
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("cached response has no ETag")
+	}
+
+	if w := get(r, "/items?page=1&size=10", http.Header{"If-None-Match": {etag}}); w.Code != http.StatusNotModified {
+		t.Errorf("revalidation = %d, want 304", w.Code)
+	}
+	if w := get(r, "/items?page=1&size=10", http.Header{"Cache-Control": {"no-cache"}}); w.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("no-cache request = %q, want a miss", w.Header().Get("X-Cache"))
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("handler called %d times, want 2", got)
+	}
+}
+
+func TestCacheSingleFlight(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	r := newCacheRouter(t, &calls, release)
+
+	const requests = 10
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if w := get(r, "/items?page=2", nil); w.Body.String() != "items 2" {
+				t.Errorf("response = %q, want items 2", w.Body.String())
+			}
+		}()
+	}
+
+	// Give the requests time to pile up behind the first one.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("handler called %d times, want 1", got)
+	}
+}
+
+func TestCacheAuthenticated(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	s := memory.NewMemory()
+	t.Cleanup(func() { _ = s.Close() })
+
+	handler := func(c *gin.Context) { c.String(http.StatusOK, "profile of "+c.GetHeader("Authorization")) }
+	r := gin.New()
+	r.GET("/me", Cache(cache.New[[]byte](s), CacheWithKeyPrefix("shared:")), handler)
+	r.GET("/vary/me", Cache(cache.New[[]byte](s), CacheWithKeyPrefix("vary:"),
+		CacheWithAuthenticated(true), CacheWithVaryHeaders("Authorization")), handler)
+
+	alice := http.Header{"Authorization": {"Bearer alice"}}
+	bob := http.Header{"Authorization": {"Bearer bob"}}
+
+	// Requests carrying credentials bypass the cache by default.
+	for _, user := range []http.Header{alice, bob, alice, bob} {
+		w := get(r, "/me", user)
+		if want := "profile of " + user.Get("Authorization"); w.Body.String() != want {
+			t.Errorf("response = %q, want %q", w.Body.String(), want)
+		}
+		if w.Header().Get("X-Cache") != "" {
+			t.Errorf("X-Cache = %q, want the cache bypassed", w.Header().Get("X-Cache"))
+		}
+	}
+	if w := get(r, "/me", http.Header{"Cookie": {"jwt=alice"}}); w.Header().Get("X-Cache") != "" {
+		t.Errorf("X-Cache = %q, want the cache bypassed for cookies", w.Header().Get("X-Cache"))
+	}
+
+	// Once opted in, the users are told apart by the vary headers.
+	for _, user := range []http.Header{alice, bob, alice, bob} {
+		w := get(r, "/vary/me", user)
+		if want := "profile of " + user.Get("Authorization"); w.Body.String() != want {
+			t.Errorf("response = %q, want %q", w.Body.String(), want)
+		}
+	}
+	if w := get(r, "/vary/me", bob); w.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("X-Cache = %q, want a hit", w.Header().Get("X-Cache"))
+	}
+}
@@ -0,0 +1,4 @@
+// Copyright 2024 eve.  All rights reserved.
+
+// Package middleware provides gin middlewares shared by the HTTP services.
+package middleware // import "github.com/snail-plus/gopkg/http/middleware"
@@ -0,0 +1,274 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package options
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/pflag"
+
+	"github.com/snail-plus/gopkg/cache"
+	"github.com/snail-plus/gopkg/cache/codec"
+	"github.com/snail-plus/gopkg/cache/store"
+	"github.com/snail-plus/gopkg/cache/store/disk"
+	"github.com/snail-plus/gopkg/cache/store/memory"
+	redisstore "github.com/snail-plus/gopkg/cache/store/redis"
+)
+
+var _ IOptions = (*CacheOptions)(nil)
+
+// CacheOptions describes a cache topology: its type, its store backends
+// and their TTLs and sizes. Use NewCache to build the cache it describes.
+type CacheOptions struct {
+	// Type is the type of the cache: noop, l2, chain or loadable.
+	Type string `json:"type" mapstructure:"type" yaml:"type"`
+	// Stores are the store backends, from the nearest to the farthest:
+	// memory, redis or disk. A chain cache has one layer per store, an l2
+	// cache takes a single remote store, and a loadable cache loads into a
+	// chain of the stores, or into the store itself when there is only one.
+	Stores []string `json:"stores" mapstructure:"stores" yaml:"stores"`
+	// Codec serializes the objects kept in the redis and disk stores: json,
+	// msgpack or gob.
+	Codec string `json:"codec" mapstructure:"codec" yaml:"codec"`
+	// Namespace is prepended to the keys of the redis store.
+	Namespace string `json:"namespace" mapstructure:"namespace" yaml:"namespace"`
+	// LocalTTL bounds how long objects are kept in the local cache of an l2
+	// cache, or in the layers of a chain cache but the last one.
+	LocalTTL time.Duration `json:"local-ttl" mapstructure:"local-ttl" yaml:"local-ttl"`
+	// LocalPolicy is the eviction policy of the memory store: lru, lfu or arc.
+	LocalPolicy string `json:"local-policy" mapstructure:"local-policy" yaml:"local-policy"`
+	// LocalMaxEntries bounds the number of objects in the memory store. For
+	// an l2 cache, it sizes the access counters of the local cache.
+	LocalMaxEntries int `json:"local-max-entries" mapstructure:"local-max-entries" yaml:"local-max-entries"`
+	// LocalMaxBytes bounds the size of the memory store.
+	LocalMaxBytes int64 `json:"local-max-bytes" mapstructure:"local-max-bytes" yaml:"local-max-bytes"`
+	// DiskDir is the directory of the disk store.
+	DiskDir string `json:"disk-dir" mapstructure:"disk-dir" yaml:"disk-dir"`
+	// DiskMaxBytes bounds the size of the disk store.
+	DiskMaxBytes int64 `json:"disk-max-bytes" mapstructure:"disk-max-bytes" yaml:"disk-max-bytes"`
+	// TTLJitter extends the TTLs of the writes by a random fraction of them,
+	// so that objects written together do not expire together.
+	TTLJitter float64 `json:"ttl-jitter" mapstructure:"ttl-jitter" yaml:"ttl-jitter"`
+	// LoadTimeout, SoftTTL and HardTTL configure a loadable cache, see
+	// cache.LoadableOptions.
+	LoadTimeout time.Duration `json:"load-timeout" mapstructure:"load-timeout" yaml:"load-timeout"`
+	SoftTTL     time.Duration `json:"soft-ttl" mapstructure:"soft-ttl" yaml:"soft-ttl"`
+	HardTTL     time.Duration `json:"hard-ttl" mapstructure:"hard-ttl" yaml:"hard-ttl"`
+	// NegativeTTL is how long a loadable cache remembers the keys the load
+	// function failed to find with store.ErrKeyNotFound. Zero disables it.
+	NegativeTTL time.Duration `json:"negative-ttl" mapstructure:"negative-ttl" yaml:"negative-ttl"`
+}
+
+// NewCacheOptions creates a CacheOptions object with default parameters.
+func NewCacheOptions() *CacheOptions {
+	return &CacheOptions{
+		Type:            cache.L2CacheType.String(),
+		Stores:          []string{redisstore.RedisType},
+		Codec:           codec.Msgpack.Name(),
+		LocalTTL:        time.Minute,
+		LocalPolicy:     string(memory.LRU),
+		LocalMaxEntries: 100000,
+	}
+}
+
+// Validate verifies flags passed to CacheOptions.
+func (o *CacheOptions) Validate() []error {
+	errs := []error{}
+
+	switch cache.CacheType(o.Type) {
+	case cache.NoopCacheType:
+		return errs
+	case cache.L2CacheType:
+		if len(o.Stores) != 1 {
+			errs = append(errs, fmt.Errorf("an l2 cache takes a single remote store, got %d", len(o.Stores)))
+		}
+	case cache.ChainCacheType, cache.LoadableCacheType:
+		if len(o.Stores) == 0 {
+			errs = append(errs, fmt.Errorf("a %s cache needs at least one store", o.Type))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown cache type %q, must be one of noop, l2, chain or loadable", o.Type))
+	}
+
+	for _, name := range o.Stores {
+		switch name {
+		case memory.MemoryType, redisstore.RedisType:
+		case disk.DiskType:
+			if o.DiskDir == "" {
+				errs = append(errs, errors.New("the disk store needs a directory"))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("unknown cache store %q, must be one of memory, redis or disk", name))
+		}
+	}
+
+	if _, err := o.codec(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if !slices.Contains([]memory.Policy{memory.LRU, memory.LFU, memory.ARC}, memory.Policy(o.LocalPolicy)) {
+		errs = append(errs, fmt.Errorf("unknown local eviction policy %q, must be one of lru, lfu or arc", o.LocalPolicy))
+	}
+
+	if o.TTLJitter < 0 || o.TTLJitter > 1 {
+		errs = append(errs, fmt.Errorf("the TTL jitter must be between 0 and 1, got %v", o.TTLJitter))
+	}
+
+	return errs
+}
+
+// AddFlags adds flags related to the cache to the specified FlagSet.
+func (o *CacheOptions) AddFlags(fs *pflag.FlagSet, prefixs ...string) {
+	fs.StringVar(&o.Type, join(prefixs...)+"cache.type", o.Type, ""+
+		"Type of the cache, one of noop, l2, chain or loadable.")
+	fs.StringSliceVar(&o.Stores, join(prefixs...)+"cache.stores", o.Stores, ""+
+		"Store backends of the cache from the nearest to the farthest, among memory, redis and disk.")
+	fs.StringVar(&o.Codec, join(prefixs...)+"cache.codec", o.Codec, ""+
+		"Codec of the objects kept in the redis and disk stores, one of json, msgpack or gob.")
+	fs.StringVar(&o.Namespace, join(prefixs...)+"cache.namespace", o.Namespace, "Namespace of the keys of the redis store.")
+	fs.DurationVar(&o.LocalTTL, join(prefixs...)+"cache.local-ttl", o.LocalTTL, ""+
+		"Maximum time objects are kept in the local cache of an l2 cache, or in the upper layers of a chain cache.")
+	fs.StringVar(&o.LocalPolicy, join(prefixs...)+"cache.local-policy", o.LocalPolicy, ""+
+		"Eviction policy of the memory store, one of lru, lfu or arc.")
+	fs.IntVar(&o.LocalMaxEntries, join(prefixs...)+"cache.local-max-entries", o.LocalMaxEntries, ""+
+		"Maximum number of objects kept in memory.")
+	fs.Int64Var(&o.LocalMaxBytes, join(prefixs...)+"cache.local-max-bytes", o.LocalMaxBytes, ""+
+		"Maximum size of the memory store, zero means unlimited.")
+	fs.StringVar(&o.DiskDir, join(prefixs...)+"cache.disk-dir", o.DiskDir, "Directory of the disk store.")
+	fs.Int64Var(&o.DiskMaxBytes, join(prefixs...)+"cache.disk-max-bytes", o.DiskMaxBytes, ""+
+		"Maximum size of the disk store, zero means unlimited.")
+	fs.Float64Var(&o.TTLJitter, join(prefixs...)+"cache.ttl-jitter", o.TTLJitter, ""+
+		"Fraction of the TTLs randomly added to them, so that objects written together do not expire together.")
+	fs.DurationVar(&o.LoadTimeout, join(prefixs...)+"cache.load-timeout", o.LoadTimeout, ""+
+		"Maximum time a caller of a loadable cache waits for a load.")
+	fs.DurationVar(&o.SoftTTL, join(prefixs...)+"cache.soft-ttl", o.SoftTTL, ""+
+		"Age after which the objects of a loadable cache are reloaded in the background.")
+	fs.DurationVar(&o.HardTTL, join(prefixs...)+"cache.hard-ttl", o.HardTTL, "TTL of the objects loaded by a loadable cache.")
+	fs.DurationVar(&o.NegativeTTL, join(prefixs...)+"cache.negative-ttl", o.NegativeTTL, ""+
+		"How long a loadable cache remembers the keys that were not found.")
+}
+
+// NewCache builds the cache described by the options. rdb is the client of
+// the redis store, it may be nil when no store is redis. load is the load
+// function of a loadable cache, the other types ignore it.
+//
+// The caches and stores are built for the lifetime of the process, they are
+// never closed.
+func NewCache[T any](o *CacheOptions, rdb redis.UniversalClient, load cache.LoadFunction[T]) (cache.Cache[T], error) {
+	if cache.CacheType(o.Type) == cache.NoopCacheType {
+		return cache.NewNoop[T](), nil
+	}
+
+	layers := make([]cache.Cache[T], 0, len(o.Stores))
+	for _, name := range o.Stores {
+		layer, err := newCacheLayer[T](o, name, rdb)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, layer)
+	}
+
+	var c cache.Cache[T]
+	switch cache.CacheType(o.Type) {
+	case cache.L2CacheType:
+		if len(layers) != 1 {
+			return nil, fmt.Errorf("an l2 cache takes a single remote store, got %d", len(layers))
+		}
+		options := []cache.L2Option{cache.L2WithLocalTTL(o.LocalTTL)}
+		if o.LocalMaxEntries > 0 {
+			// Ristretto advises ten counters per entry.
+			options = append(options, cache.L2WithNumCounters(int64(o.LocalMaxEntries)*10))
+		}
+		c = cache.NewL2(layers[0], options...)
+	case cache.ChainCacheType:
+		c = newCacheChain(o, layers)
+	case cache.LoadableCacheType:
+		if load == nil {
+			return nil, errors.New("a loadable cache needs a load function")
+		}
+		options := []cache.LoadableOption{
+			cache.LoadableWithLoadTimeout(o.LoadTimeout),
+			cache.LoadableWithSoftTTL(o.SoftTTL),
+			cache.LoadableWithHardTTL(o.HardTTL),
+		}
+		if o.NegativeTTL > 0 {
+			options = append(options, cache.LoadableWithNegativeCache(func(err error) bool {
+				return errors.Is(err, store.ErrKeyNotFound)
+			}, o.NegativeTTL))
+		}
+		c = cache.NewLoadable(load, newCacheChain(o, layers), options...)
+	default:
+		return nil, fmt.Errorf("unknown cache type %q", o.Type)
+	}
+
+	if o.TTLJitter > 0 {
+		c = cache.Wrap(c, cache.TTLJitter[T](o.TTLJitter))
+	}
+
+	return c, nil
+}
+
+// newCacheChain chains the layers, or returns the layer itself when there is only one.
+func newCacheChain[T any](o *CacheOptions, layers []cache.Cache[T]) cache.Cache[T] {
+	if len(layers) == 1 {
+		return layers[0]
+	}
+
+	options := []cache.ChainOption{}
+	if o.LocalTTL > 0 {
+		for i := range layers[:len(layers)-1] {
+			options = append(options, cache.ChainWithLayerMaxTTL(i, o.LocalTTL))
+		}
+	}
+
+	return cache.NewChainWithOptions(layers, options...)
+}
+
+// newCacheLayer builds the cache over the named store.
+func newCacheLayer[T any](o *CacheOptions, name string, rdb redis.UniversalClient) (cache.Cache[T], error) {
+	switch name {
+	case memory.MemoryType:
+		return cache.New[T](memory.NewMemory(
+			memory.WithPolicy(memory.Policy(o.LocalPolicy)),
+			memory.WithMaxEntries(o.LocalMaxEntries),
+			memory.WithMaxBytes(o.LocalMaxBytes),
+		)), nil
+	case redisstore.RedisType:
+		if rdb == nil {
+			return nil, errors.New("the redis store needs a redis client")
+		}
+		c, err := o.codec()
+		if err != nil {
+			return nil, err
+		}
+		s := redisstore.NewRedis(rdb, redisstore.WithNamespace(o.Namespace))
+		return cache.New[T](s, cache.DelegateWithCodec(c)), nil
+	case disk.DiskType:
+		c, err := o.codec()
+		if err != nil {
+			return nil, err
+		}
+		s, err := disk.NewDisk(o.DiskDir, disk.WithMaxBytes(o.DiskMaxBytes))
+		if err != nil {
+			return nil, err
+		}
+		return cache.New[T](s, cache.DelegateWithCodec(c)), nil
+	default:
+		return nil, fmt.Errorf("unknown cache store %q", name)
+	}
+}
+
+// codec returns the codec named by the options.
+func (o *CacheOptions) codec() (codec.Codec, error) {
+	for _, c := range []codec.Codec{codec.JSON, codec.Msgpack, codec.Gob} {
+		if c.Name() == o.Codec {
+			return c, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown cache codec %q, must be one of json, msgpack or gob", o.Codec)
+}
@@ -0,0 +1,178 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package options
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/snail-plus/gopkg/cache"
+	"github.com/snail-plus/gopkg/cache/store"
+)
+
+func TestCacheOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(o *CacheOptions)
+		wantErr bool
+	}{
+		{"defaults", func(o *CacheOptions) {}, false},
+		{"noop ignores the stores", func(o *CacheOptions) { o.Type, o.Stores = "noop", nil }, false},
+		{"chain", func(o *CacheOptions) { o.Type, o.Stores = "chain", []string{"memory", "redis"} }, false},
+		{"unknown type", func(o *CacheOptions) { o.Type = "lru" }, true},
+		{"l2 without store", func(o *CacheOptions) { o.Stores = nil }, true},
+		{"l2 with two stores", func(o *CacheOptions) { o.Stores = []string{"memory", "redis"} }, true},
+		{"chain without store", func(o *CacheOptions) { o.Type, o.Stores = "chain", nil }, true},
+		{"loadable without store", func(o *CacheOptions) { o.Type, o.Stores = "loadable", nil }, true},
+		{"unknown store", func(o *CacheOptions) { o.Stores = []string{"memcached"} }, true},
+		{"disk without directory", func(o *CacheOptions) { o.Stores = []string{"disk"} }, true},
+		{"unknown codec", func(o *CacheOptions) { o.Codec = "xml" }, true},
+		{"unknown policy", func(o *CacheOptions) { o.LocalPolicy = "fifo" }, true},
+		{"negative jitter", func(o *CacheOptions) { o.TTLJitter = -0.1 }, true},
+		{"jitter above 1", func(o *CacheOptions) { o.TTLJitter = 1.5 }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := NewCacheOptions()
+			tt.modify(o)
+			if errs := o.Validate(); (len(errs) > 0) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", errs, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewCache(t *testing.T) {
+	// The client fails fast, telling apart the caches reaching redis.
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", DialTimeout: 100 * time.Millisecond, MaxRetries: -1})
+	t.Cleanup(func() { _ = rdb.Close() })
+
+	load := func(ctx context.Context, key any) (string, error) {
+		return "loaded", nil
+	}
+
+	tests := []struct {
+		name   string
+		modify func(o *CacheOptions)
+		// check verifies the cache built.
+		check func(t *testing.T, c cache.Cache[string])
+	}{
+		{
+			name:   "noop",
+			modify: func(o *CacheOptions) { o.Type = "noop" },
+			check: func(t *testing.T, c cache.Cache[string]) {
+				if _, ok := c.(*cache.NoopCache[string]); !ok {
+					t.Errorf("NewCache() = %T, want a noop cache", c)
+				}
+			},
+		},
+		{
+			name:   "memory only",
+			modify: func(o *CacheOptions) { o.Type, o.Stores = "chain", []string{"memory"} },
+			check: func(t *testing.T, c cache.Cache[string]) {
+				if _, ok := c.(*cache.DelegateCache[string]); !ok {
+					t.Fatalf("NewCache() = %T, want the memory store itself", c)
+				}
+				// Only the memory store is reached.
+				ctx := context.Background()
+				if err := c.Set(ctx, "key", "value"); err != nil {
+					t.Fatalf("Set() error = %v", err)
+				}
+				if obj, err := c.Get(ctx, "key"); err != nil || obj != "value" {
+					t.Errorf("Get() = %q, %v, want value", obj, err)
+				}
+			},
+		},
+		{
+			name:   "redis only",
+			modify: func(o *CacheOptions) { o.Type, o.Stores = "chain", []string{"redis"} },
+			check: func(t *testing.T, c cache.Cache[string]) {
+				if _, ok := c.(*cache.DelegateCache[string]); !ok {
+					t.Fatalf("NewCache() = %T, want the redis store itself", c)
+				}
+				if _, err := c.Get(context.Background(), "key"); err == nil || errors.Is(err, store.ErrKeyNotFound) {
+					t.Errorf("Get() error = %v, want the redis connection error", err)
+				}
+			},
+		},
+		{
+			name:   "memory and redis",
+			modify: func(o *CacheOptions) { o.Type, o.Stores = "chain", []string{"memory", "redis"} },
+			check: func(t *testing.T, c cache.Cache[string]) {
+				chain, ok := c.(*cache.ChainCache[string])
+				if !ok {
+					t.Fatalf("NewCache() = %T, want a chain cache", c)
+				}
+				defer chain.Close()
+				if layers := chain.LayerStats(); len(layers) != 2 {
+					t.Errorf("LayerStats() has %d layers, want 2", len(layers))
+				}
+			},
+		},
+		{
+			name:   "l2",
+			modify: func(o *CacheOptions) {},
+			check: func(t *testing.T, c cache.Cache[string]) {
+				l2, ok := c.(*cache.L2Cache[string])
+				if !ok {
+					t.Fatalf("NewCache() = %T, want an l2 cache", c)
+				}
+				defer l2.Close()
+				if _, err := l2.Get(context.Background(), "key"); err == nil || errors.Is(err, store.ErrKeyNotFound) {
+					t.Errorf("Get() error = %v, want the redis connection error", err)
+				}
+			},
+		},
+		{
+			name:   "loadable",
+			modify: func(o *CacheOptions) { o.Type, o.Stores = "loadable", []string{"memory"} },
+			check: func(t *testing.T, c cache.Cache[string]) {
+				if _, ok := c.(*cache.LoadableCache[string]); !ok {
+					t.Fatalf("NewCache() = %T, want a loadable cache", c)
+				}
+				if obj, err := c.Get(context.Background(), "key"); err != nil || obj != "loaded" {
+					t.Errorf("Get() = %q, %v, want loaded", obj, err)
+				}
+			},
+		},
+		{
+			name:   "jitter",
+			modify: func(o *CacheOptions) { o.Type, o.Stores, o.TTLJitter = "chain", []string{"memory"}, 0.1 },
+			check: func(t *testing.T, c cache.Cache[string]) {
+				if _, ok := cache.Unwrap(c).(*cache.DelegateCache[string]); !ok {
+					t.Errorf("NewCache() wraps %T, want the memory store", cache.Unwrap(c))
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := NewCacheOptions()
+			tt.modify(o)
+			if errs := o.Validate(); len(errs) > 0 {
+				t.Fatalf("Validate() = %v", errs)
+			}
+
+			c, err := NewCache[string](o, rdb, load)
+			if err != nil {
+				t.Fatalf("NewCache() error = %v", err)
+			}
+			tt.check(t, c)
+		})
+	}
+
+	if _, err := NewCache[string](NewCacheOptions(), nil, nil); err == nil {
+		t.Error("NewCache() without redis client error = nil, want an error")
+	}
+	o := NewCacheOptions()
+	o.Type, o.Stores = "loadable", []string{"memory"}
+	if _, err := NewCache[string](o, nil, nil); err == nil {
+		t.Error("NewCache() of a loadable cache without load function error = nil, want an error")
+	}
+}
@@ -3,21 +3,42 @@
 package options
 
 import (
+	"crypto/ecdsa"
+	"crypto/rsa"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/asaskevich/govalidator"
+	gojwt "github.com/golang-jwt/jwt/v5"
 	"github.com/spf13/pflag"
+
+	"github.com/snail-plus/gopkg/authn/jwt"
 )
 
 var _ IOptions = (*JWTOptions)(nil)
 
 // JWTOptions contains configuration items related to API server features.
 type JWTOptions struct {
-	Key           string        `json:"key" mapstructure:"key" yaml:"key"`
-	Expired       time.Duration `json:"expired" mapstructure:"expired" yaml:"expired"`
+	Key     string        `json:"key" mapstructure:"key" yaml:"key"`
+	Expired time.Duration `json:"expired" mapstructure:"expired" yaml:"expired"`
+	// MaxRefresh is the absolute lifetime of a session: the tokens can be
+	// refreshed until MaxRefresh has passed since signing in, and no later.
+	// It must not be shorter than Expired, so a configuration raising
+	// Expired above the 2h default must set MaxRefresh too. Zero means
+	// sessions never end.
 	MaxRefresh    time.Duration `json:"max-refresh" mapstructure:"max-refresh" yaml:"max-refresh"`
 	SigningMethod string        `json:"signing-method" mapstructure:"signing-method" yaml:"signing-method"`
+	// KeyID identifies the signing key in the kid header of the tokens.
+	KeyID string `json:"key-id" mapstructure:"key-id" yaml:"key-id"`
+	// PrivateKeyFile is the PEM private key signing the tokens with the RSA,
+	// ECDSA or EdDSA signing methods. Key is the secret of the HMAC methods.
+	PrivateKeyFile string `json:"private-key-file" mapstructure:"private-key-file" yaml:"private-key-file"`
+	// PublicKeyFiles are the PEM public keys of retired signing keys, as
+	// kid=path entries, which still verify the tokens they signed. Retired
+	// RSA keys are assumed to use the current signing method if it is an RSA
+	// one, and RS256 otherwise.
+	PublicKeyFiles []string `json:"public-key-files" mapstructure:"public-key-files" yaml:"public-key-files"`
 }
 
 // NewJWTOptions creates a JWTOptions object with default parameters.
@@ -28,6 +49,7 @@ func NewJWTOptions() *JWTOptions {
 		Expired:       2 * time.Hour,
 		MaxRefresh:    2 * time.Hour,
 		SigningMethod: "HS512",
+		KeyID:         "default",
 	}
 }
 
@@ -36,8 +58,27 @@ func NewJWTOptions() *JWTOptions {
 func (s *JWTOptions) Validate() []error {
 	var errs []error
 
-	if !govalidator.StringLength(s.Key, "6", "32") {
-		errs = append(errs, fmt.Errorf("--jwt.key must larger than 5 and little than 33"))
+	method := gojwt.GetSigningMethod(s.SigningMethod)
+	if method == nil {
+		errs = append(errs, fmt.Errorf("--jwt.signing-method %q is not supported", s.SigningMethod))
+	}
+
+	if _, ok := method.(*gojwt.SigningMethodHMAC); ok || method == nil {
+		if !govalidator.StringLength(s.Key, "6", "32") {
+			errs = append(errs, fmt.Errorf("--jwt.key must larger than 5 and little than 33"))
+		}
+	} else if s.PrivateKeyFile == "" {
+		errs = append(errs, fmt.Errorf("--jwt.private-key-file is required by the %s signing method", s.SigningMethod))
+	}
+
+	if s.MaxRefresh > 0 && s.MaxRefresh < s.Expired {
+		errs = append(errs, fmt.Errorf("--jwt.max-refresh must not be less than --jwt.expired, or 0 for no limit"))
+	}
+
+	for _, entry := range s.PublicKeyFiles {
+		if kid, path, ok := strings.Cut(entry, "="); !ok || kid == "" || path == "" {
+			errs = append(errs, fmt.Errorf("--jwt.public-key-files entry %q must be kid=path", entry))
+		}
 	}
 
 	return errs
@@ -54,6 +95,80 @@ func (s *JWTOptions) AddFlags(fs *pflag.FlagSet, prefixs ...string) {
 	fs.StringVar(&s.Key, "jwt.key", s.Key, "Private key used to sign jwt token.")
 	fs.DurationVar(&s.Expired, "jwt.expired", s.Expired, "JWT token expiration time.")
 	fs.DurationVar(&s.MaxRefresh, "jwt.max-refresh", s.MaxRefresh, ""+
-		"This field allows clients to refresh their token until MaxRefresh has passed.")
+		"This field allows clients to refresh their token until MaxRefresh has passed since signing in, 0 means no limit.")
 	fs.StringVar(&s.SigningMethod, "jwt.signing-method", s.SigningMethod, "JWT token signature method.")
+	fs.StringVar(&s.KeyID, "jwt.key-id", s.KeyID, "Identifier of the signing key, set in the kid header of the tokens.")
+	fs.StringVar(&s.PrivateKeyFile, "jwt.private-key-file", s.PrivateKeyFile, ""+
+		"PEM private key signing the tokens with the RSA, ECDSA or EdDSA signing methods.")
+	fs.StringSliceVar(&s.PublicKeyFiles, "jwt.public-key-files", s.PublicKeyFiles, ""+
+		"PEM public keys of retired signing keys as kid=path entries, which still verify the tokens they signed.")
+}
+
+// NewKeySet loads the signing key and the public keys of the retired signing keys.
+func (s *JWTOptions) NewKeySet() (*jwt.KeySet, error) {
+	method := gojwt.GetSigningMethod(s.SigningMethod)
+	if method == nil {
+		return nil, fmt.Errorf("signing method %q is not supported", s.SigningMethod)
+	}
+
+	var signingKey any = []byte(s.Key)
+	if _, ok := method.(*gojwt.SigningMethodHMAC); !ok {
+		key, err := jwt.LoadPrivateKey(s.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		signingKey = key
+	}
+
+	keys := []*jwt.Key{{ID: s.KeyID, Method: method, Private: signingKey}}
+	for _, entry := range s.PublicKeyFiles {
+		kid, path, _ := strings.Cut(entry, "=")
+		key, err := jwt.LoadPublicKey(path)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, &jwt.Key{ID: kid, Method: signingMethodOf(key, method), Public: key})
+	}
+
+	return jwt.NewKeySet(keys...)
+}
+
+// NewAuthenticator creates a JWT authenticator signing the tokens with the key set of the options.
+func (s *JWTOptions) NewAuthenticator(store jwt.Storer, opts ...jwt.Option) (*jwt.JWTAuth, error) {
+	keySet, err := s.NewKeySet()
+	if err != nil {
+		return nil, err
+	}
+
+	opts = append([]jwt.Option{
+		jwt.WithKeySet(keySet),
+		jwt.WithExpired(s.Expired),
+		jwt.WithMaxRefresh(s.MaxRefresh),
+	}, opts...)
+	return jwt.New(store, opts...), nil
+}
+
+// signingMethodOf returns the signing method of a retired public key: the
+// current method when it uses the same kind of key, or the most common method
+// for that kind of key.
+func signingMethodOf(key any, current gojwt.SigningMethod) gojwt.SigningMethod {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		switch current.(type) {
+		case *gojwt.SigningMethodRSA, *gojwt.SigningMethodRSAPSS:
+			return current
+		}
+		return gojwt.SigningMethodRS256
+	case *ecdsa.PublicKey:
+		switch k.Curve.Params().BitSize {
+		case 384:
+			return gojwt.SigningMethodES384
+		case 521:
+			return gojwt.SigningMethodES512
+		default:
+			return gojwt.SigningMethodES256
+		}
+	default:
+		return gojwt.SigningMethodEdDSA
+	}
 }
@@ -3,6 +3,7 @@
 package options
 
 import (
+	"errors"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -15,17 +16,26 @@ var _ IOptions = (*RedisOptions)(nil)
 
 // RedisOptions defines options for redis cluster.
 type RedisOptions struct {
-	Addr         string        `json:"addr" mapstructure:"addr" yaml:"addr"`
-	Username     string        `json:"username" mapstructure:"username" yaml:"username"`
-	Password     string        `json:"password" mapstructure:"password" yaml:"password"`
-	Database     int           `json:"database" mapstructure:"database" yaml:"database"`
-	MaxRetries   int           `json:"max-retries" mapstructure:"max-retries" yaml:"max-retries"`
-	MinIdleConns int           `json:"min-idle-conns" mapstructure:"min-idle-conns" yaml:"min-idle-conns"`
-	DialTimeout  time.Duration `json:"dial-timeout" mapstructure:"dial-timeout" yaml:"dial-timeout"`
-	ReadTimeout  time.Duration `json:"read-timeout" mapstructure:"read-timeout" yaml:"read-timeout"`
-	WriteTimeout time.Duration `json:"write-timeout" mapstructure:"write-timeout" yaml:"write-timeout"`
-	PoolTimeout  time.Duration `json:"pool-time" mapstructure:"pool-time" yaml:"pool-time"`
-	PoolSize     int           `json:"pool-size" mapstructure:"pool-size" yaml:"pool-size"`
+	Addr string `json:"addr" mapstructure:"addr" yaml:"addr"`
+	// Addrs holds the seed addresses of a cluster, or the sentinel addresses
+	// when MasterName is set. When empty, Addr is used.
+	Addrs      []string `json:"addrs" mapstructure:"addrs" yaml:"addrs"`
+	MasterName string   `json:"master-name" mapstructure:"master-name" yaml:"master-name"`
+	// IsClusterMode creates a cluster client even when a single seed or
+	// configuration endpoint address is given.
+	IsClusterMode  bool          `json:"cluster-mode" mapstructure:"cluster-mode" yaml:"cluster-mode"`
+	RouteByLatency bool          `json:"route-by-latency" mapstructure:"route-by-latency" yaml:"route-by-latency"`
+	RouteRandomly  bool          `json:"route-randomly" mapstructure:"route-randomly" yaml:"route-randomly"`
+	Username       string        `json:"username" mapstructure:"username" yaml:"username"`
+	Password       string        `json:"password" mapstructure:"password" yaml:"password"`
+	Database       int           `json:"database" mapstructure:"database" yaml:"database"`
+	MaxRetries     int           `json:"max-retries" mapstructure:"max-retries" yaml:"max-retries"`
+	MinIdleConns   int           `json:"min-idle-conns" mapstructure:"min-idle-conns" yaml:"min-idle-conns"`
+	DialTimeout    time.Duration `json:"dial-timeout" mapstructure:"dial-timeout" yaml:"dial-timeout"`
+	ReadTimeout    time.Duration `json:"read-timeout" mapstructure:"read-timeout" yaml:"read-timeout"`
+	WriteTimeout   time.Duration `json:"write-timeout" mapstructure:"write-timeout" yaml:"write-timeout"`
+	PoolTimeout    time.Duration `json:"pool-time" mapstructure:"pool-time" yaml:"pool-time"`
+	PoolSize       int           `json:"pool-size" mapstructure:"pool-size" yaml:"pool-size"`
 	// tracing switch
 	EnableTrace bool `json:"enable-trace" mapstructure:"enable-trace" yaml:"enable-trace"`
 }
@@ -59,12 +69,34 @@ func (o *RedisOptions) Validate() []error {
 		o.PoolTimeout = o.ReadTimeout + 1*time.Second
 	}
 
+	// With a master name, the route flags and cluster mode select a sentinel
+	// client routing read-only commands to the replicas, which keeps the
+	// selected database.
+	clusterMode := o.MasterName == "" && (o.IsClusterMode || len(o.Addrs) > 1)
+	if clusterMode && o.Database != 0 {
+		errs = append(errs, errors.New("--redis.database must be 0 in cluster mode, redis cluster only supports database 0"))
+	}
+	if !clusterMode && o.MasterName == "" && (o.RouteByLatency || o.RouteRandomly) {
+		errs = append(errs, errors.New("--redis.route-by-latency and --redis.route-randomly require cluster mode or --redis.master-name"))
+	}
+
 	return errs
 }
 
 // AddFlags adds flags related to redis storage for a specific APIServer to the specified FlagSet.
 func (o *RedisOptions) AddFlags(fs *pflag.FlagSet, prefixs ...string) {
 	fs.StringVar(&o.Addr, "redis.addr", o.Addr, "Address of your Redis server(ip:port).")
+	fs.StringSliceVar(&o.Addrs, "redis.addrs", o.Addrs, ""+
+		"Seed addresses of your Redis cluster, or sentinel addresses when --redis.master-name is set.")
+	fs.StringVar(&o.MasterName, "redis.master-name", o.MasterName, "Name of the master monitored by the Redis sentinels.")
+	fs.BoolVar(&o.IsClusterMode, "redis.cluster-mode", o.IsClusterMode, ""+
+		"Connect to a Redis cluster, even through a single seed or configuration endpoint address.")
+	fs.BoolVar(&o.RouteByLatency, "redis.route-by-latency", o.RouteByLatency, ""+
+		"Route read-only commands of a Redis cluster, or of the master and replicas monitored by the "+
+		"sentinels when --redis.master-name is set, to the node with the lowest latency.")
+	fs.BoolVar(&o.RouteRandomly, "redis.route-randomly", o.RouteRandomly, ""+
+		"Route read-only commands of a Redis cluster, or of the master and replicas monitored by the "+
+		"sentinels when --redis.master-name is set, to a random node.")
 	fs.StringVar(&o.Username, "redis.username", o.Username, "Username for access to redis service.")
 	fs.StringVar(&o.Password, "redis.password", o.Password, "Optional auth password for redis db.")
 	fs.IntVar(&o.Database, "redis.database", o.Database, "Database to be selected after connecting to the server.")
@@ -80,19 +112,25 @@ func (o *RedisOptions) AddFlags(fs *pflag.FlagSet, prefixs ...string) {
 	fs.BoolVar(&o.EnableTrace, "redis.enable-trace", o.EnableTrace, "Redis hook tracing (using open telemetry).")
 }
 
-func (o *RedisOptions) NewClient() (*redis.Client, error) {
+// NewClient creates a standalone, sentinel or cluster redis client with the given options.
+func (o *RedisOptions) NewClient() (redis.UniversalClient, error) {
 	opts := &db.RedisOptions{
-		Addr:         o.Addr,
-		Username:     o.Username,
-		Password:     o.Password,
-		Database:     o.Database,
-		MaxRetries:   o.MaxRetries,
-		MinIdleConns: o.MinIdleConns,
-		DialTimeout:  o.DialTimeout,
-		ReadTimeout:  o.ReadTimeout,
-		WriteTimeout: o.WriteTimeout,
-		PoolSize:     o.PoolSize,
-		PoolTimeout:  o.PoolTimeout,
+		Addr:           o.Addr,
+		Addrs:          o.Addrs,
+		MasterName:     o.MasterName,
+		IsClusterMode:  o.IsClusterMode,
+		RouteByLatency: o.RouteByLatency,
+		RouteRandomly:  o.RouteRandomly,
+		Username:       o.Username,
+		Password:       o.Password,
+		Database:       o.Database,
+		MaxRetries:     o.MaxRetries,
+		MinIdleConns:   o.MinIdleConns,
+		DialTimeout:    o.DialTimeout,
+		ReadTimeout:    o.ReadTimeout,
+		WriteTimeout:   o.WriteTimeout,
+		PoolSize:       o.PoolSize,
+		PoolTimeout:    o.PoolTimeout,
 	}
 
 	rdb, err := db.NewRedis(opts)
@@ -0,0 +1,35 @@
+// Copyright 2024 eve.  All rights reserved.
+
+package options
+
+import "testing"
+
+func TestRedisOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(o *RedisOptions)
+		wantErr bool
+	}{
+		{"defaults", func(o *RedisOptions) {}, false},
+		{"cluster", func(o *RedisOptions) { o.Addrs = []string{"a:6379", "b:6379"} }, false},
+		{"cluster with database", func(o *RedisOptions) { o.IsClusterMode, o.Database = true, 1 }, true},
+		{"cluster routing", func(o *RedisOptions) { o.IsClusterMode, o.RouteRandomly = true, true }, false},
+		{"standalone routing", func(o *RedisOptions) { o.RouteByLatency = true }, true},
+		{"sentinel with database", func(o *RedisOptions) {
+			o.MasterName, o.Addrs, o.Database = "master", []string{"a:26379", "b:26379"}, 1
+		}, false},
+		{"sentinel routing", func(o *RedisOptions) {
+			o.MasterName, o.Addrs, o.RouteByLatency, o.Database = "master", []string{"a:26379", "b:26379"}, true, 1
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := NewRedisOptions()
+			tt.modify(o)
+			if errs := o.Validate(); (len(errs) > 0) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", errs, tt.wantErr)
+			}
+		})
+	}
+}